@@ -0,0 +1,218 @@
+package puppetmaster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// BatchOptions configures Client.RunJSONL().
+type BatchOptions struct {
+	// Concurrency is the number of jobs executed at the same time, 1 by default.
+	Concurrency int
+	// PreserveOrder writes the results in the order of the input lines instead of the order the jobs finished in.
+	PreserveOrder bool
+	// Resume reads the output of a previous, interrupted run. Input lines it holds a result for are skipped, so the
+	// new results can be appended to it. An incomplete last line is ignored.
+	Resume io.Reader
+}
+
+// BatchResult is a single output line of Client.RunJSONL(). It holds either the finished job or the error that kept
+// the request on the given input line from finishing.
+type BatchResult struct {
+	Line  int    `json:"line"`
+	Job   *Job   `json:"job,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type batchTask struct {
+	seq        int
+	line       int
+	jobRequest *JobRequest
+	err        error
+}
+
+type batchDone struct {
+	seq    int
+	result *BatchResult
+}
+
+// RunJSONL executes a JobRequest from every non-empty line of r and writes a BatchResult line for each of them to w.
+// Invalid lines and failed jobs produce a result with an error instead of aborting the run. When ctx is done, the
+// results of the jobs cancelled by it are not written, so they are executed again when resuming; RunJSONL then
+// returns the context's error. Other errors are only returned if reading r or writing w failed.
+func (c *Client) RunJSONL(ctx context.Context, r io.Reader, w io.Writer, opts *BatchOptions) error {
+	if opts == nil {
+		opts = &BatchOptions{}
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	answered := map[int]bool{}
+	if opts.Resume != nil {
+		var err error
+		if answered, err = answeredLines(opts.Resume); err != nil {
+			return fmt.Errorf("failed to read previous results: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tasks := make(chan batchTask)
+	done := make(chan batchDone)
+
+	var readErr error
+	go func() {
+		defer close(tasks)
+		readErr = readBatchTasks(ctx, r, answered, tasks)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for task := range tasks {
+				done <- batchDone{seq: task.seq, result: c.runBatchTask(ctx, task)}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	enc := json.NewEncoder(w)
+	pending := map[int]*BatchResult{}
+	next := 0
+
+	var writeErr error
+	write := func(result *BatchResult) {
+		if writeErr != nil || result == nil {
+			return
+		}
+
+		if writeErr = enc.Encode(result); writeErr != nil {
+			// stop executing, the results could not be stored anyway
+			cancel()
+		}
+	}
+
+	for d := range done {
+		if !opts.PreserveOrder {
+			write(d.result)
+			continue
+		}
+
+		pending[d.seq] = d.result
+		for result, ok := pending[next]; ok; result, ok = pending[next] {
+			write(result)
+			delete(pending, next)
+			next++
+		}
+	}
+
+	switch {
+	case writeErr != nil:
+		return fmt.Errorf("failed to write result: %v", writeErr)
+	case readErr != nil:
+		return fmt.Errorf("failed to read requests: %v", readErr)
+	}
+
+	return ctx.Err()
+}
+
+// runBatchTask executes a single task. It returns nil if the job was interrupted by ctx, so no result is written.
+func (c *Client) runBatchTask(ctx context.Context, task batchTask) *BatchResult {
+	result := &BatchResult{Line: task.line}
+	if task.err != nil {
+		result.Error = task.err.Error()
+		return result
+	}
+
+	job, err := c.ExecuteSyncWithOptions(ctx, task.jobRequest, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Job = job
+
+	return result
+}
+
+// readBatchTasks sends a task for every non-empty line of r that was not answered yet. Lines are not limited in
+// length, as job code can be long.
+func readBatchTasks(ctx context.Context, r io.Reader, answered map[int]bool, tasks chan<- batchTask) error {
+	reader := bufio.NewReader(r)
+	seq := 0
+
+	for line := 1; ; line++ {
+		b, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && !answered[line] {
+			task := batchTask{seq: seq, line: line, jobRequest: &JobRequest{}}
+			if decodeErr := json.Unmarshal(trimmed, task.jobRequest); decodeErr != nil {
+				task.err = fmt.Errorf("invalid job request: %v", decodeErr)
+			}
+
+			select {
+			case tasks <- task:
+				seq++
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// answeredLines returns the input lines that have a result in the output of a previous run.
+func answeredLines(r io.Reader) (map[int]bool, error) {
+	answered := map[int]bool{}
+	reader := bufio.NewReader(r)
+
+	for {
+		b, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		// an incomplete last line was cut off while writing, its line is executed again
+		if err == io.EOF && !bytes.HasSuffix(b, []byte("\n")) {
+			return answered, nil
+		}
+
+		result := &BatchResult{}
+		if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 {
+			if err := json.Unmarshal(trimmed, result); err != nil {
+				return nil, fmt.Errorf("invalid result %q: %v", trimmed, err)
+			}
+			answered[result.Line] = true
+		}
+
+		if err == io.EOF {
+			return answered, nil
+		}
+	}
+}
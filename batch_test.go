@@ -0,0 +1,163 @@
+package puppetmaster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchHandler creates jobs with the code as UUID and finishes them on the first poll. Jobs with the code "broken"
+// cannot be fetched, jobs with a code starting with "slow" take a while.
+func batchHandler(t *testing.T) (http.Handler, *sync.Map) {
+	created := &sync.Map{}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/cancel") {
+			rw.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		if req.Method == http.MethodPost {
+			jobRequest := &JobRequest{}
+			if err := json.NewDecoder(req.Body).Decode(jobRequest); err != nil {
+				t.Errorf("failed to decode job request: %v", err)
+			}
+			created.Store(jobRequest.Code, true)
+
+			rw.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(rw).Encode(&JobResponse{Data: Job{UUID: jobRequest.Code, Status: StatusCreated}})
+			return
+		}
+
+		uuid := strings.TrimPrefix(req.URL.Path, "/jobs/")
+		if uuid == "broken" {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if strings.HasPrefix(uuid, "slow") {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		_ = json.NewEncoder(rw).Encode(&JobResponse{Data: Job{
+			UUID:    uuid,
+			Status:  StatusDone,
+			Results: map[string]interface{}{"code": uuid},
+		}})
+	}), created
+}
+
+func decodeBatchResults(t *testing.T, out *bytes.Buffer) []BatchResult {
+	var results []BatchResult
+
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		result := BatchResult{}
+		if err := dec.Decode(&result); err != nil {
+			t.Fatalf("failed to decode result: %v", err)
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func TestClient_RunJSONL(t *testing.T) {
+	handler, _ := batchHandler(t)
+	c := newTestClient(t, handler)
+	defer c.server.Close()
+	c.client.SetSyncSleepMs(1)
+
+	input := strings.Join([]string{
+		`{"code": "slow-first"}`,
+		``,
+		`{"code": "second"}`,
+		`not json`,
+		`{"code": "broken"}`,
+		`{"code": "last"}`,
+	}, "\n")
+
+	out := &bytes.Buffer{}
+	err := c.client.RunJSONL(context.Background(), strings.NewReader(input), out, &BatchOptions{
+		Concurrency:   3,
+		PreserveOrder: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to run requests: %v", err)
+	}
+
+	results := decodeBatchResults(t, out)
+	expLines := []int{1, 3, 4, 5, 6}
+	if len(results) != len(expLines) {
+		t.Fatalf("Expected %d results, got %+v", len(expLines), results)
+	}
+
+	for i, result := range results {
+		if result.Line != expLines[i] {
+			t.Errorf("Expected result %d for line %d, got line %d", i, expLines[i], result.Line)
+		}
+
+		failed := result.Line == 4 || result.Line == 5
+		if failed && (result.Error == "" || result.Job != nil) {
+			t.Errorf("Expected error for line %d, got %+v", result.Line, result)
+		}
+
+		if !failed && (result.Error != "" || result.Job == nil || result.Job.Status != StatusDone) {
+			t.Errorf("Expected done job for line %d, got %+v", result.Line, result)
+		}
+	}
+}
+
+func TestClient_RunJSONL_Resume(t *testing.T) {
+	handler, created := batchHandler(t)
+	c := newTestClient(t, handler)
+	defer c.server.Close()
+	c.client.SetSyncSleepMs(1)
+
+	input := "{\"code\": \"a\"}\n{\"code\": \"b\"}\n{\"code\": \"c\"}\n"
+
+	// line 1 finished, line 3 was cut off while writing
+	previous := "{\"line\":1,\"error\":\"failed before\"}\n{\"line\":3,\"jo"
+
+	out := &bytes.Buffer{}
+	err := c.client.RunJSONL(context.Background(), strings.NewReader(input), out, &BatchOptions{
+		Resume: strings.NewReader(previous),
+	})
+	if err != nil {
+		t.Fatalf("failed to run requests: %v", err)
+	}
+
+	results := decodeBatchResults(t, out)
+	if len(results) != 2 || results[0].Line != 2 || results[1].Line != 3 {
+		t.Errorf("Expected results for lines 2 and 3, got %+v", results)
+	}
+
+	if _, ok := created.Load("a"); ok {
+		t.Error("Expected answered line not to be executed again")
+	}
+}
+
+func TestClient_RunJSONL_Cancel(t *testing.T) {
+	handler, _ := batchHandler(t)
+	c := newTestClient(t, handler)
+	defer c.server.Close()
+	c.client.SetSyncSleepMs(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	out := &bytes.Buffer{}
+	err := c.client.RunJSONL(ctx, strings.NewReader(`{"code": "slow"}`), out, nil)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("Expected interrupted job not to be written, got %s", out)
+	}
+}
@@ -0,0 +1,166 @@
+package puppetmaster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prunePerPage is the page size used to walk through jobs when pruning.
+const prunePerPage = 100
+
+// SetBulkConcurrency sets how many requests bulk operations like DeleteJobs send at the same time, 4 by default.
+func (c *Client) SetBulkConcurrency(concurrency uint) {
+	if concurrency == 0 {
+		concurrency = 1
+	}
+
+	c.bulkConcurrency = concurrency
+}
+
+// DeleteReport describes the outcome of a bulk deletion.
+type DeleteReport struct {
+	// Deleted holds the UUIDs of all deleted jobs, including jobs that did not exist anymore.
+	Deleted []string
+	// Failed holds the error for every UUID that could not be deleted.
+	Failed map[string]error
+}
+
+// Err returns an error summarizing all failed deletions, or nil if there are none.
+func (r *DeleteReport) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+
+	uuids := make([]string, 0, len(r.Failed))
+	for uuid := range r.Failed {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	errs := make([]string, 0, len(uuids))
+	for _, uuid := range uuids {
+		errs = append(errs, fmt.Sprintf("%s: %v", uuid, r.Failed[uuid]))
+	}
+
+	return fmt.Errorf("failed to delete %d jobs: %v", len(errs), strings.Join(errs, ", "))
+}
+
+// DeleteJobs deletes all given jobs, sending up to Client.SetBulkConcurrency() requests at once. Jobs that do not
+// exist are treated as deleted, so it is safe to run repeatedly.
+func (c *Client) DeleteJobs(ctx context.Context, uuids []string) *DeleteReport {
+	report := &DeleteReport{Failed: map[string]error{}}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.bulkConcurrency)
+
+	for _, uuid := range uuids {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			mu.Lock()
+			report.Failed[uuid] = ctx.Err()
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(uuid string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			err := c.DeleteJobContext(ctx, uuid)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil && err != ErrNotFound {
+				report.Failed[uuid] = err
+				return
+			}
+			report.Deleted = append(report.Deleted, uuid)
+		}(uuid)
+	}
+
+	wg.Wait()
+	sort.Strings(report.Deleted)
+
+	return report
+}
+
+// PruneOptions selects the jobs removed by Client.Prune().
+type PruneOptions struct {
+	// OlderThan selects jobs finished, or created if they never started, more than this duration ago. It has to be
+	// positive unless All is set.
+	OlderThan time.Duration
+	// All selects jobs regardless of their age. It has to be set explicitly, so zero-value options do not remove
+	// all jobs.
+	All bool
+	// Status selects jobs with the given status only. If empty, only done and cancelled jobs are selected, so
+	// running jobs are never removed by accident.
+	Status string
+	// DryRun only reports the selected jobs without deleting them.
+	DryRun bool
+}
+
+// PruneReport describes the outcome of Client.Prune().
+type PruneReport struct {
+	DeleteReport
+
+	// Matched holds the UUIDs of all jobs selected by the PruneOptions.
+	Matched []string
+}
+
+// Prune pages through all jobs and deletes the ones selected by opts. Like DeleteJobs, it treats jobs that vanished
+// in the meantime as deleted, so it can run repeatedly, e.g. as a periodic retention cleanup.
+func (c *Client) Prune(ctx context.Context, opts PruneOptions) (*PruneReport, error) {
+	if opts.OlderThan <= 0 && !opts.All {
+		return nil, ErrNoPruneAge
+	}
+
+	cutoff := time.Now().Add(-opts.OlderThan)
+	report := &PruneReport{DeleteReport: DeleteReport{Failed: map[string]error{}}}
+
+	// collect first, deleting while paging would shift the pages
+	jobs := c.IterateJobs(ctx, opts.Status, prunePerPage)
+	for jobs.Next() {
+		if opts.selects(jobs.Job(), cutoff) {
+			report.Matched = append(report.Matched, jobs.Job().UUID)
+		}
+	}
+
+	if err := jobs.Err(); err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun || len(report.Matched) == 0 {
+		return report, nil
+	}
+
+	report.DeleteReport = *c.DeleteJobs(ctx, report.Matched)
+
+	return report, nil
+}
+
+func (o *PruneOptions) selects(job *Job, cutoff time.Time) bool {
+	if o.Status == "" && !job.finished() {
+		return false
+	}
+
+	if o.Status != "" && job.Status != o.Status {
+		return false
+	}
+
+	reference := job.CreatedAt
+	if job.FinishedAt != nil {
+		reference = *job.FinishedAt
+	}
+
+	return o.All || reference.Before(cutoff)
+}
@@ -0,0 +1,104 @@
+package puppetmaster
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// deletingHandler lists the jobs of the get-jobs-response.json fixture and records deletions.
+type deletingHandler struct {
+	t       *testing.T
+	jobs    []byte
+	mu      sync.Mutex
+	deleted []string
+}
+
+func (h *deletingHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet {
+		_, _ = rw.Write(h.jobs)
+		return
+	}
+
+	uuid := strings.TrimPrefix(req.URL.Path, "/jobs/")
+	switch uuid {
+	case "missing":
+		rw.WriteHeader(http.StatusNotFound)
+	case "broken":
+		rw.WriteHeader(http.StatusInternalServerError)
+	default:
+		h.mu.Lock()
+		h.deleted = append(h.deleted, uuid)
+		h.mu.Unlock()
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestClient_DeleteJobs(t *testing.T) {
+	h := &deletingHandler{t: t}
+	c := newTestClient(t, h)
+	c.client.SetBulkConcurrency(2)
+
+	report := c.client.DeleteJobs(context.Background(), []string{"b", "missing", "a", "broken"})
+
+	if strings.Join(report.Deleted, ",") != "a,b,missing" {
+		t.Errorf("Expected a, b and missing to be deleted, got %v", report.Deleted)
+	}
+
+	if len(report.Failed) != 1 || report.Failed["broken"] == nil {
+		t.Errorf("Expected broken to fail, got %v", report.Failed)
+	}
+
+	if err := report.Err(); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Expected error mentioning broken, got %v", err)
+	}
+}
+
+func TestClient_Prune(t *testing.T) {
+	cases := []struct {
+		opts    PruneOptions
+		matched int
+		deleted int
+	}{
+		{opts: PruneOptions{OlderThan: 24 * time.Hour}, matched: 3, deleted: 3},
+		{opts: PruneOptions{OlderThan: 24 * time.Hour, DryRun: true}, matched: 3, deleted: 0},
+		{opts: PruneOptions{OlderThan: 24 * time.Hour, Status: StatusCreated}, matched: 7, deleted: 7},
+		{opts: PruneOptions{OlderThan: 100 * 365 * 24 * time.Hour}, matched: 0, deleted: 0},
+		{opts: PruneOptions{OlderThan: 100 * 365 * 24 * time.Hour, All: true}, matched: 3, deleted: 3},
+		{opts: PruneOptions{All: true, DryRun: true}, matched: 3, deleted: 0},
+	}
+
+	for i, c := range cases {
+		h := &deletingHandler{t: t, jobs: readTestData(t, "get-jobs-response.json")}
+		tc := newTestClient(t, h)
+
+		report, err := tc.client.Prune(context.Background(), c.opts)
+		if err != nil {
+			t.Fatalf("case %d: failed to prune: %v", i, err)
+		}
+
+		if len(report.Matched) != c.matched {
+			t.Errorf("case %d: Expected %d matched jobs, got %d", i, c.matched, len(report.Matched))
+		}
+
+		if len(h.deleted) != c.deleted || len(report.Deleted) != c.deleted {
+			t.Errorf("case %d: Expected %d deleted jobs, got %d (reported %d)", i, c.deleted, len(h.deleted), len(report.Deleted))
+		}
+	}
+
+	h := &deletingHandler{t: t, jobs: readTestData(t, "get-jobs-response.json")}
+	tc := newTestClient(t, h)
+
+	for _, opts := range []PruneOptions{{}, {OlderThan: -time.Hour}, {Status: StatusDone}} {
+		if _, err := tc.client.Prune(context.Background(), opts); err != ErrNoPruneAge {
+			t.Errorf("Expected ErrNoPruneAge for %+v, got %v", opts, err)
+		}
+	}
+
+	if len(h.deleted) != 0 {
+		t.Errorf("Expected no jobs to be deleted, got %d", len(h.deleted))
+	}
+}
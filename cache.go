@@ -0,0 +1,205 @@
+package puppetmaster
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache stores finished jobs by the content hash of the JobRequest they were created from, see JobRequest.Hash().
+type Cache interface {
+	// Get returns the job stored for key, or nil if there is none or it expired.
+	Get(key string) (*Job, error)
+	// Set stores the job for key. A ttl of 0 keeps it until it is evicted.
+	Set(key string, job *Job, ttl time.Duration) error
+}
+
+// SetCache makes ExecuteSync return a job from the cache when it was executed with the same code, modules and vars
+// before. Successfully finished jobs are stored for the given ttl.
+func (c *Client) SetCache(cache Cache, ttl time.Duration) {
+	c.cache = cache
+	c.cacheTTL = ttl
+}
+
+type cacheEntry struct {
+	Key       string    `json:"key"`
+	Job       *Job      `json:"job"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (e *cacheEntry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
+}
+
+func newCacheEntry(key string, job *Job, ttl time.Duration, now time.Time) *cacheEntry {
+	e := &cacheEntry{Key: key, Job: job}
+	if ttl > 0 {
+		e.ExpiresAt = now.Add(ttl)
+	}
+
+	return e
+}
+
+// copyJob returns a deep copy of job by passing it through JSON, like FileCache does, so callers can not modify
+// cached jobs.
+func copyJob(job *Job) (*Job, error) {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+
+	copied := &Job{}
+	if err := json.Unmarshal(b, copied); err != nil {
+		return nil, err
+	}
+
+	return copied, nil
+}
+
+// MemoryCache is an in-memory Cache evicting the least recently used job once it is full. Jobs are copied when set
+// and returned, so every caller gets its own copy.
+type MemoryCache struct {
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// NewMemoryCache returns a new MemoryCache holding up to size jobs. The size has to be at least 1.
+func NewMemoryCache(size int) (*MemoryCache, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidCacheSize, size)
+	}
+
+	return &MemoryCache{
+		size:    size,
+		now:     time.Now,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}, nil
+}
+
+// Get implements Cache.
+func (m *MemoryCache) Get(key string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, nil
+	}
+
+	e := el.Value.(*cacheEntry)
+	if e.expired(m.now()) {
+		m.order.Remove(el)
+		delete(m.entries, key)
+		return nil, nil
+	}
+
+	m.order.MoveToFront(el)
+
+	return copyJob(e.Job)
+}
+
+// Set implements Cache.
+func (m *MemoryCache) Set(key string, job *Job, ttl time.Duration) error {
+	job, err := copyJob(job)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		m.order.Remove(el)
+	}
+
+	m.entries[key] = m.order.PushFront(newCacheEntry(key, job, ttl, m.now()))
+
+	for m.order.Len() > m.size {
+		el := m.order.Back()
+		m.order.Remove(el)
+		delete(m.entries, el.Value.(*cacheEntry).Key)
+	}
+
+	return nil
+}
+
+// FileCache is a Cache storing every job as JSON file in a directory, so results survive restarts.
+type FileCache struct {
+	dir string
+	now func() time.Time
+}
+
+// NewFileCache returns a new FileCache storing jobs in dir, which is created if it does not exist.
+func NewFileCache(dir string) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	return &FileCache{dir: dir, now: time.Now}, nil
+}
+
+// Get implements Cache.
+func (f *FileCache) Get(key string) (*Job, error) {
+	b, err := os.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e := &cacheEntry{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, fmt.Errorf("failed to decode cache entry %q: %v", key, err)
+	}
+
+	if e.expired(f.now()) {
+		if err := os.Remove(f.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	return e.Job, nil
+}
+
+// Set implements Cache.
+func (f *FileCache) Set(key string, job *Job, ttl time.Duration) error {
+	b, err := json.Marshal(newCacheEntry(key, job, ttl, f.now()))
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so concurrent readers never see a partial entry
+	tmp, err := os.CreateTemp(f.dir, filepath.Base(key)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path(key))
+}
+
+func (f *FileCache) path(key string) string {
+	return filepath.Join(f.dir, filepath.Base(key)+".json")
+}
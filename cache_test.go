@@ -0,0 +1,153 @@
+package puppetmaster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testCaches(t *testing.T) map[string]func(now func() time.Time) Cache {
+	return map[string]func(now func() time.Time) Cache{
+		"memory": func(now func() time.Time) Cache {
+			c, err := NewMemoryCache(2)
+			if err != nil {
+				t.Fatalf("failed to create memory cache: %v", err)
+			}
+			c.now = now
+			return c
+		},
+		"file": func(now func() time.Time) Cache {
+			c, err := NewFileCache(t.TempDir())
+			if err != nil {
+				t.Fatalf("failed to create file cache: %v", err)
+			}
+			c.now = now
+			return c
+		},
+	}
+}
+
+func TestCache_GetSet(t *testing.T) {
+	for name, newCache := range testCaches(t) {
+		now := time.Now()
+		cache := newCache(func() time.Time { return now })
+
+		if job, err := cache.Get("missing"); job != nil || err != nil {
+			t.Errorf("%s: Expected miss, got %+v, %v", name, job, err)
+		}
+
+		if err := cache.Set("key", &Job{UUID: "uuid"}, time.Minute); err != nil {
+			t.Fatalf("%s: failed to set job: %v", name, err)
+		}
+
+		job, err := cache.Get("key")
+		if err != nil || job == nil || job.UUID != "uuid" {
+			t.Errorf("%s: Expected hit, got %+v, %v", name, job, err)
+		}
+
+		now = now.Add(2 * time.Minute)
+		if job, err := cache.Get("key"); job != nil || err != nil {
+			t.Errorf("%s: Expected expired entry to miss, got %+v, %v", name, job, err)
+		}
+	}
+}
+
+func TestMemoryCache_Evict(t *testing.T) {
+	cache, err := NewMemoryCache(2)
+	if err != nil {
+		t.Fatalf("failed to create memory cache: %v", err)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		if err := cache.Set(key, &Job{UUID: key}, 0); err != nil {
+			t.Fatalf("failed to set job: %v", err)
+		}
+	}
+
+	// touch a, so b is the least recently used
+	if job, _ := cache.Get("a"); job == nil {
+		t.Fatal("Expected a to be cached")
+	}
+
+	if err := cache.Set("c", &Job{UUID: "c"}, 0); err != nil {
+		t.Fatalf("failed to set job: %v", err)
+	}
+
+	for key, exp := range map[string]bool{"a": true, "b": false, "c": true} {
+		job, _ := cache.Get(key)
+		if (job != nil) != exp {
+			t.Errorf("Expected %s cached == %v", key, exp)
+		}
+	}
+}
+
+func TestMemoryCache_Copy(t *testing.T) {
+	cache, err := NewMemoryCache(1)
+	if err != nil {
+		t.Fatalf("failed to create memory cache: %v", err)
+	}
+
+	job := &Job{UUID: "uuid", Results: map[string]interface{}{"items": []interface{}{"a"}}}
+	if err := cache.Set("key", job, 0); err != nil {
+		t.Fatalf("failed to set job: %v", err)
+	}
+	job.Results["items"] = []interface{}{"changed"}
+
+	first, _ := cache.Get("key")
+	first.Results["items"].([]interface{})[0] = "changed"
+
+	second, _ := cache.Get("key")
+	if second == first || second.Results["items"].([]interface{})[0] != "a" {
+		t.Errorf("Expected every caller to get an unchanged copy, got %+v", second.Results)
+	}
+
+	for _, size := range []int{0, -1} {
+		if _, err := NewMemoryCache(size); !errors.Is(err, ErrInvalidCacheSize) {
+			t.Errorf("Expected ErrInvalidCacheSize for size %d, got %v", size, err)
+		}
+	}
+}
+
+func TestClient_ExecuteSyncCache(t *testing.T) {
+	c := newTestClient(t, sequenceHandler(t, Job{UUID: "uuid", Status: StatusCreated}, Job{UUID: "uuid", Status: StatusDone}))
+	c.client.SetSyncSleepMs(1)
+
+	cache, err := NewMemoryCache(10)
+	if err != nil {
+		t.Fatalf("failed to create memory cache: %v", err)
+	}
+	c.client.SetCache(cache, time.Minute)
+
+	jobReq := &JobRequest{Code: "test"}
+	if _, err := c.client.ExecuteSync(jobReq); err != nil {
+		t.Fatalf("failed to execute job: %v", err)
+	}
+
+	cached := &Job{UUID: "cached", Status: StatusDone}
+	if err := cache.Set(jobReq.Hash(), cached, time.Minute); err != nil {
+		t.Fatalf("failed to set job: %v", err)
+	}
+
+	job, err := c.client.ExecuteSync(jobReq)
+	if err != nil {
+		t.Fatalf("failed to execute job: %v", err)
+	}
+
+	if job.UUID != "cached" {
+		t.Errorf("Expected cached job, got %+v", job)
+	}
+
+	job, err = c.client.ExecuteSyncWithOptions(context.Background(), jobReq, &SyncOptions{BypassCache: true})
+	if err != nil {
+		t.Fatalf("failed to execute job: %v", err)
+	}
+
+	if job.UUID != "uuid" {
+		t.Errorf("Expected bypass to execute the job, got %+v", job)
+	}
+
+	if job, _ := cache.Get(jobReq.Hash()); job == nil || job.UUID != "uuid" {
+		t.Errorf("Expected bypass to refresh the cache, got %+v", job)
+	}
+}
@@ -0,0 +1,61 @@
+package puppetmaster
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// cancelTimeout limits how long cancelling a job abandoned by a cancelled context may take.
+const cancelTimeout = 10 * time.Second
+
+// CancelJob asks the puppet master to abort a created, queued or running job. Unlike DeleteJob the job is kept,
+// including logs and results collected so far, with StatusCancelled. Cancelling a job that is already done fails
+// with ErrJobFinished.
+func (c *Client) CancelJob(ctx context.Context, uuid string) error {
+	jobURL := c.buildURL(fmt.Sprintf("/jobs/%v/cancel", uuid), map[string]string{})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, jobURL, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer closeBody(res.Body)
+
+	switch res.StatusCode {
+	case 200, 202, 204:
+		return nil
+	case 404:
+		return ErrNotFound
+	case 409:
+		return ErrJobFinished
+	default:
+		return unexpectedResponse(res)
+	}
+}
+
+func (j *Job) finished() bool {
+	return j.Status == StatusDone || j.Status == StatusCancelled
+}
+
+// cancelAbandonedJob cancels a job nobody waits for anymore. The context of the waiter is already done, so a fresh
+// one is used.
+func (c *Client) cancelAbandonedJob(uuid string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+
+	err := c.CancelJob(ctx, uuid)
+	if err == nil || err == ErrJobFinished || err == ErrNotFound {
+		c.completeInJournal(uuid)
+		return
+	}
+
+	if c.debug {
+		log.Printf("failed to cancel abandoned job %v: %v", uuid, err)
+	}
+}
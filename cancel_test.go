@@ -0,0 +1,98 @@
+package puppetmaster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_CancelJob(t *testing.T) {
+	cases := []struct {
+		code int
+		err  error
+	}{
+		{code: 202, err: nil},
+		{code: 204, err: nil},
+		{code: 404, err: ErrNotFound},
+		{code: 409, err: ErrJobFinished},
+	}
+
+	for i, c := range cases {
+		var path, method string
+		tc := newTestClient(t, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			path, method = req.URL.Path, req.Method
+			rw.WriteHeader(c.code)
+		}))
+
+		if err := tc.client.CancelJob(context.Background(), "uuid"); err != c.err {
+			t.Errorf("case %d: Expected error %v, got %v", i, c.err, err)
+		}
+
+		if path != "/jobs/uuid/cancel" || method != http.MethodPost {
+			t.Errorf("case %d: Unexpected request %v %v", i, method, path)
+		}
+	}
+}
+
+func TestClient_ExecuteSyncCancelsJob(t *testing.T) {
+	var mu sync.Mutex
+	cancelled := false
+
+	c := newTestClient(t, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/cancel"):
+			cancelled = true
+			rw.WriteHeader(http.StatusAccepted)
+		case req.Method == http.MethodPost:
+			rw.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(rw).Encode(&JobResponse{Data: Job{UUID: "uuid", Status: StatusCreated}})
+		default:
+			_ = json.NewEncoder(rw).Encode(&JobResponse{Data: Job{UUID: "uuid", Status: StatusQueued}})
+		}
+	}))
+	c.client.SetSyncSleepMs(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.client.ExecuteSyncWithOptions(ctx, &JobRequest{Code: "test"}, nil); err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !cancelled {
+		t.Error("Expected the abandoned job to be cancelled")
+	}
+}
+
+func TestClient_ExecuteSyncCancelledJob(t *testing.T) {
+	c := newTestClient(t, sequenceHandler(t, Job{UUID: "uuid", Status: StatusQueued}, Job{UUID: "uuid", Status: StatusCancelled}))
+	c.client.SetSyncSleepMs(1)
+
+	var events []JobEvent
+	job, err := c.client.ExecuteSyncWithOptions(context.Background(), &JobRequest{Code: "test"}, &SyncOptions{
+		OnStatusChange: func(change StatusChange) {
+			events = append(events, change.Event)
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to execute job: %v", err)
+	}
+
+	if job.Status != StatusCancelled {
+		t.Errorf("Expected cancelled job, got status %v", job.Status)
+	}
+
+	if len(events) != 2 || events[1] != EventCancelled {
+		t.Errorf("Expected queued and cancelled events, got %v", events)
+	}
+}
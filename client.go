@@ -2,22 +2,44 @@ package puppetmaster
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Client represents a client to interact with the puppet-master API.
 type Client struct {
-	apiToken    string
+	tokens      TokenSource
 	baseURL     *url.URL
 	debug       bool
 	syncSleepMs uint
+
+	strictDecoding  bool
+	maxResponseSize int64
+
+	notifier         CompletionNotifier
+	notifierFallback time.Duration
+
+	attemptsMu sync.Mutex
+	attempts   map[string]*idempotencyAttempt
+
+	cache    Cache
+	cacheTTL time.Duration
+
+	journal Journal
+
+	limiter *rateLimiter
+
+	bulkConcurrency uint
+
+	httpClient *http.Client
 }
 
 // NewClient returns a new Client instance.
@@ -27,26 +49,87 @@ func NewClient(baseURL, apiToken string) (*Client, error) {
 		return nil, ErrEmptyAPIToken
 	}
 
+	return NewClientWithTokenSource(baseURL, NewStaticTokenSource(apiToken))
+}
+
+// NewClientWithTokenSource returns a new Client instance fetching the API token from the given TokenSource before
+// every request.
+func NewClientWithTokenSource(baseURL string, tokens TokenSource) (*Client, error) {
+	if tokens == nil {
+		return nil, ErrNoTokenSource
+	}
+
 	c := &Client{
-		apiToken:    apiToken,
-		syncSleepMs: 500,
+		tokens:          tokens,
+		syncSleepMs:     500,
+		attempts:        map[string]*idempotencyAttempt{},
+		bulkConcurrency: 4,
+		httpClient:      http.DefaultClient,
+		maxResponseSize: defaultMaxResponseSize,
 	}
 
 	var err error
-	if c.baseURL, err = url.Parse(baseURL); err != nil {
+	if c.baseURL, err = parseBaseURL(baseURL); err != nil {
 		return nil, err
 	}
 
 	return c, nil
 }
 
+// SetHTTPClient replaces the http.Client used to send requests, http.DefaultClient by default, e.g. to configure
+// timeouts, proxies or a custom http.RoundTripper.
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	c.httpClient = httpClient
+}
+
+// SetMaxResponseSize limits the size of response bodies read by the client, 32 MiB by default. Reading a larger body
+// fails with ErrResponseTooLarge instead of exhausting memory. A size <= 0 disables the limit.
+func (c *Client) SetMaxResponseSize(size int64) {
+	c.maxResponseSize = size
+}
+
 // EnableDebugLogs enables debug logging of requests and responses.
 func (c *Client) EnableDebugLogs() {
 	c.debug = true
 }
 
-func (c *Client) addAuthentication(req *http.Request) {
-	req.Header.Add(authHeader, fmt.Sprintf("Bearer %s", c.apiToken))
+func (c *Client) addAuthentication(req *http.Request) error {
+	token, ok := tokenOverride(req.Context())
+	if !ok {
+		var err error
+		if token, err = c.tokens.Token(req.Context()); err != nil {
+			return fmt.Errorf("failed to get API token: %v", err)
+		}
+	}
+
+	req.Header.Set(authHeader, fmt.Sprintf("Bearer %s", token))
+
+	return nil
+}
+
+// parseBaseURL parses an absolute http(s) URL and strips trailing slashes from its path.
+func parseBaseURL(baseURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBaseURL, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: scheme must be http or https, got %q", ErrInvalidBaseURL, u.Scheme)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("%w: host may not be empty", ErrInvalidBaseURL)
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+
+	return u, nil
 }
 
 func (c *Client) buildURL(subPath string, query map[string]string) string {
@@ -63,20 +146,65 @@ func (c *Client) buildURL(subPath string, query map[string]string) string {
 	return base.String()
 }
 
-// do sends a request and does additional logging of request and response, if debug mode is enabled.
+// do authenticates and sends a request. When the token got rejected and the TokenSource can be invalidated, the
+// request is retried once with a fresh token.
 func (c *Client) do(req *http.Request) (*http.Response, error) {
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	if err := c.addAuthentication(req); err != nil {
+		return nil, err
+	}
+
+	res, err := c.send(req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	invalidator, ok := c.tokens.(InvalidatingTokenSource)
+	if _, overridden := tokenOverride(req.Context()); !ok || overridden || (req.Body != nil && req.GetBody == nil) {
+		return res, nil
+	}
+
+	closeBody(res.Body)
+
+	invalidator.Invalidate()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.addAuthentication(retry); err != nil {
+		return nil, err
+	}
+
+	return c.send(retry)
+}
+
+// send sends a request and does additional logging of request and response, if debug mode is enabled.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.limiter != nil {
+		if err := c.limiter.wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
 
 	if c.debug {
 		dumpRequest(req)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 
+	if c.maxResponseSize > 0 {
+		res.Body = &limitedBody{ReadCloser: res.Body, limit: c.maxResponseSize, remaining: c.maxResponseSize}
+	}
+
 	if c.debug {
 		dumpResponse(res)
 	}
@@ -86,35 +214,39 @@ func (c *Client) do(req *http.Request) (*http.Response, error) {
 
 // GetJobs returns all jobs as a paginated list
 func (c *Client) GetJobs(page, perPage uint) (*JobPagination, error) {
-	return c.GetJobsByStatus("", page, perPage)
+	return c.GetJobsByStatusContext(context.Background(), "", page, perPage)
 }
 
 // GetJobsByStatus lists jobs with the given status as a paginated list
 func (c *Client) GetJobsByStatus(status string, page, perPage uint) (*JobPagination, error) {
+	return c.GetJobsByStatusContext(context.Background(), status, page, perPage)
+}
+
+// GetJobsByStatusContext works like GetJobsByStatus, but sends the request with the given context
+func (c *Client) GetJobsByStatusContext(ctx context.Context, status string, page, perPage uint) (*JobPagination, error) {
 	jobURL := c.buildURL("/jobs", map[string]string{
 		"status":   status,
-		"page":     string(page),
-		"per_page": string(perPage),
+		"page":     strconv.FormatUint(uint64(page), 10),
+		"per_page": strconv.FormatUint(uint64(perPage), 10),
 	})
 
-	req, err := http.NewRequest(http.MethodGet, jobURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jobURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	c.addAuthentication(req)
-
 	res, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer closeBody(res.Body)
 
 	if res.StatusCode != 200 {
 		return nil, unexpectedResponse(res)
 	}
 
 	jobs := &JobPagination{}
-	if err = json.NewDecoder(res.Body).Decode(jobs); err != nil {
+	if err = c.decode(res, jobs); err != nil {
 		return nil, err
 	}
 
@@ -123,6 +255,11 @@ func (c *Client) GetJobsByStatus(status string, page, perPage uint) (*JobPaginat
 
 // CreateJob schedules a new job for execution
 func (c *Client) CreateJob(jobRequest *JobRequest) (*Job, error) {
+	return c.CreateJobContext(context.Background(), jobRequest)
+}
+
+// CreateJobContext works like CreateJob, but sends the request with the given context
+func (c *Client) CreateJobContext(ctx context.Context, jobRequest *JobRequest) (*Job, error) {
 	jobURL := c.buildURL("/jobs", map[string]string{})
 
 	if strings.TrimSpace(jobRequest.Code) == "" {
@@ -136,53 +273,74 @@ func (c *Client) CreateJob(jobRequest *JobRequest) (*Job, error) {
 		jobRequest.Vars = map[string]string{}
 	}
 
+	if jobRequest.IdempotencyKey != "" {
+		if job, err := c.findPreviousAttempt(ctx, jobRequest); job != nil || err != nil {
+			return job, err
+		}
+	}
+
 	body, err := json.Marshal(jobRequest)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, jobURL, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, jobURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
 
-	c.addAuthentication(req)
+	if jobRequest.IdempotencyKey != "" {
+		req.Header.Set(idempotencyHeader, jobRequest.IdempotencyKey)
+	}
 
 	res, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer closeBody(res.Body)
+
+	if res.StatusCode != 201 && res.StatusCode != 422 {
+		// client errors are final, no job was created a retry could find
+		if res.StatusCode >= 400 && res.StatusCode < 500 {
+			c.forgetAttempt(jobRequest.IdempotencyKey)
+		}
+
+		return nil, unexpectedResponse(res)
+	}
 
 	job := &JobResponse{}
-	if err = json.NewDecoder(res.Body).Decode(job); err != nil {
+	if err = c.decode(res, job); err != nil {
 		return nil, err
 	}
 
-	if res.StatusCode != 201 {
-		if res.StatusCode == 422 {
-			return nil, unprocessableEntity(res, job.Errors)
-		}
-
-		return nil, unexpectedResponse(res)
+	if res.StatusCode == 422 {
+		c.forgetAttempt(jobRequest.IdempotencyKey)
+		return nil, unprocessableEntity(res, job.Errors)
 	}
 
+	c.rememberCreatedJob(jobRequest.IdempotencyKey, job.Data.UUID)
+
 	return &job.Data, nil
 }
 
 // GetJob fetches a single job
 func (c *Client) GetJob(uuid string) (*Job, error) {
+	return c.GetJobContext(context.Background(), uuid)
+}
+
+// GetJobContext works like GetJob, but sends the request with the given context
+func (c *Client) GetJobContext(ctx context.Context, uuid string) (*Job, error) {
 	jobURL := c.buildURL(fmt.Sprintf("/jobs/%v", uuid), map[string]string{})
-	req, err := http.NewRequest(http.MethodGet, jobURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jobURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	c.addAuthentication(req)
-
 	res, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer closeBody(res.Body)
 
 	if res.StatusCode != 200 {
 		if res.StatusCode == 404 {
@@ -193,7 +351,7 @@ func (c *Client) GetJob(uuid string) (*Job, error) {
 	}
 
 	job := &JobResponse{}
-	if err = json.NewDecoder(res.Body).Decode(job); err != nil {
+	if err = c.decode(res, job); err != nil {
 		return nil, err
 	}
 
@@ -202,22 +360,22 @@ func (c *Client) GetJob(uuid string) (*Job, error) {
 
 // DeleteJob deletes a job
 func (c *Client) DeleteJob(uuid string) error {
+	return c.DeleteJobContext(context.Background(), uuid)
+}
+
+// DeleteJobContext works like DeleteJob, but sends the request with the given context
+func (c *Client) DeleteJobContext(ctx context.Context, uuid string) error {
 	jobURL := c.buildURL(fmt.Sprintf("/jobs/%v", uuid), map[string]string{})
-	req, err := http.NewRequest(http.MethodDelete, jobURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, jobURL, nil)
 	if err != nil {
 		return err
 	}
 
-	c.addAuthentication(req)
-
 	res, err := c.do(req)
 	if err != nil {
 		return err
 	}
-
-	if err := res.Body.Close(); err != nil {
-		return fmt.Errorf("failed to close response body: %v", err)
-	}
+	defer closeBody(res.Body)
 
 	if res.StatusCode != 204 {
 		if res.StatusCode == 404 {
@@ -239,26 +397,5 @@ func (c *Client) SetSyncSleepMs(syncSleepMs uint) {
 // The amount of time is changeable by calling Client.SetSyncSleepMs(). Since the job is done even when an error occurred
 // we can assure to return the finished job at some point in time.
 func (c *Client) ExecuteSync(jobRequest *JobRequest) (*Job, error) {
-	job, err := c.CreateJob(jobRequest)
-
-	if err != nil {
-		return nil, err
-	}
-
-	for {
-		job, err = c.GetJob(job.UUID)
-		if err != nil {
-			if err == io.EOF {
-				continue
-			}
-
-			return nil, err
-		}
-
-		if job.Status == StatusDone {
-			return job, nil
-		}
-
-		time.Sleep(time.Duration(c.syncSleepMs) * time.Millisecond)
-	}
+	return c.ExecuteSyncWithOptions(context.Background(), jobRequest, nil)
 }
@@ -2,6 +2,7 @@ package puppetmaster
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -13,7 +14,9 @@ func TestAddHeader(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 
 	t.Logf("Headers: %v", req.Header)
-	c.client.addAuthentication(req)
+	if err := c.client.addAuthentication(req); err != nil {
+		t.Fatalf("failed to add authentication: %v", err)
+	}
 	t.Logf("Headers: %v", req.Header)
 
 	if len(req.Header) != 1 {
@@ -131,3 +134,39 @@ func TestClient_DeleteJobNotFound(t *testing.T) {
 		t.Fatalf("Expected to get ErrNotFound, got %v", err)
 	}
 }
+
+func TestNewClientBaseURL(t *testing.T) {
+	cases := []struct {
+		baseURL string
+		exp     string
+		valid   bool
+	}{
+		{baseURL: "https://puppet-master.io/api/v1/teams/my-team", exp: "https://puppet-master.io/api/v1/teams/my-team", valid: true},
+		{baseURL: "https://puppet-master.io/api/v1/teams/my-team//", exp: "https://puppet-master.io/api/v1/teams/my-team", valid: true},
+		{baseURL: " http://localhost:8080/ ", exp: "http://localhost:8080", valid: true},
+		{baseURL: "puppet-master.io/api/v1", valid: false},
+		{baseURL: "/api/v1/teams/my-team", valid: false},
+		{baseURL: "ftp://puppet-master.io", valid: false},
+		{baseURL: "https:///api/v1", valid: false},
+		{baseURL: "http://[::1", valid: false},
+	}
+
+	for i, c := range cases {
+		client, err := NewClient(c.baseURL, "token")
+		if !c.valid {
+			if !errors.Is(err, ErrInvalidBaseURL) {
+				t.Errorf("case %d: Expected ErrInvalidBaseURL for %q, got %v", i, c.baseURL, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("case %d: failed to construct client for %q: %v", i, c.baseURL, err)
+			continue
+		}
+
+		if client.baseURL.String() != c.exp {
+			t.Errorf("case %d: Expected base url %v, got %v", i, c.exp, client.baseURL)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Command puppet-master runs puppet-master jobs from the command line.
+//
+// Usage:
+//
+//	puppet-master run-jsonl [flags] <requests.jsonl>
+//
+// The base URL of the team is read from -base-url or PUPPET_MASTER_BASE_URL, the API token from the environment
+// variable PUPPET_MASTER_API_TOKEN.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+const tokenEnv = "PUPPET_MASTER_API_TOKEN"
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "run-jsonl":
+		err = runJSONL(ctx, os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: puppet-master <command> [flags]
+
+Commands:
+  run-jsonl  execute a job for every line of a JSON lines file
+
+The API token is read from the environment variable %v.
+`, tokenEnv)
+}
+
+func runJSONL(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("run-jsonl", flag.ExitOnError)
+	baseURL := fs.String("base-url", os.Getenv("PUPPET_MASTER_BASE_URL"), "base URL of the team, e.g. https://puppet-master.io/api/v1/teams/my-team")
+	output := fs.String("o", "", "file to write the results to, stdout if empty")
+	concurrency := fs.Int("c", 1, "number of jobs executed at the same time")
+	ordered := fs.Bool("ordered", false, "write results in the order of the requests")
+	resume := fs.Bool("resume", false, "skip requests that already have a result in the output file and append to it")
+	debug := fs.Bool("debug", false, "log requests and responses")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: puppet-master run-jsonl [flags] <requests.jsonl>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if *resume && *output == "" {
+		return fmt.Errorf("-resume requires an output file")
+	}
+
+	client, err := puppetmaster.NewClientWithTokenSource(*baseURL, puppetmaster.EnvTokenSource(tokenEnv))
+	if err != nil {
+		return err
+	}
+
+	if *debug {
+		client.EnableDebugLogs()
+	}
+
+	in, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	opts := &puppetmaster.BatchOptions{Concurrency: *concurrency, PreserveOrder: *ordered}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := openOutput(*output, *resume)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if *resume {
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			opts.Resume = f
+		}
+		out = f
+	}
+
+	return client.RunJSONL(ctx, in, out, opts)
+}
+
+// openOutput opens the output file. When resuming, an incomplete last line left by an interrupted run is cut off,
+// so appended results start on a new line.
+func openOutput(path string, resume bool) (*os.File, error) {
+	if !resume {
+		return os.Create(path)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	complete := len(b)
+	for complete > 0 && b[complete-1] != '\n' {
+		complete--
+	}
+
+	if err := f.Truncate(int64(complete)); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
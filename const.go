@@ -4,22 +4,71 @@ import "errors"
 
 // possible status values
 const (
-	StatusCreated = "created"
-	StatusQueued  = "queued"
-	StatusDone    = "done"
+	StatusCreated   = "created"
+	StatusQueued    = "queued"
+	StatusDone      = "done"
+	StatusCancelled = "cancelled"
 )
 
-const authHeader = "Authorization"
+const (
+	authHeader        = "Authorization"
+	idempotencyHeader = "Idempotency-Key"
+)
 
 var (
 	// ErrEmptyAPIToken is thrown when the apiToken given to NewClient() is empty.
 	ErrEmptyAPIToken = errors.New("apiToken may not be empty")
 
+	// ErrInvalidBaseURL is thrown when the baseURL given to NewClient() is not an absolute http(s) URL.
+	ErrInvalidBaseURL = errors.New("invalid baseURL")
+
+	// ErrUnauthorized is thrown when the puppet master rejects the API token.
+	ErrUnauthorized = errors.New("API token was rejected")
+
+	// ErrIncompatibleAPIVersion is thrown when the puppet master speaks an API version the client does not support.
+	ErrIncompatibleAPIVersion = errors.New("incompatible API version")
+
+	// ErrNoTokenSource is thrown when the TokenSource given to NewClientWithTokenSource() is nil.
+	ErrNoTokenSource = errors.New("tokenSource may not be nil")
+
 	// ErrNotFound is thrown when given job UUID was not found by the puppet master
 	ErrNotFound = errors.New("job was not found by given UUID")
 
+	// ErrJobFinished is thrown when you try to cancel a job that is already done
+	ErrJobFinished = errors.New("job is already finished")
+
 	// ErrEmptyCode is thrown when you try to create a job with empty code
 	ErrEmptyCode = errors.New("given JobRequest's code may not be empty")
+
+	// ErrInvalidJobRequest is thrown when the puppet master rejects a job request as invalid.
+	ErrInvalidJobRequest = errors.New("job request is invalid")
+
+	// ErrEmptyTeam is thrown when a team added to a MultiClient has an empty name.
+	ErrEmptyTeam = errors.New("team may not be empty")
+
+	// ErrInvalidTeam is thrown when a team added to a MultiClient has a name that is no single path segment.
+	ErrInvalidTeam = errors.New("team may not contain slashes or be a dot segment")
+
+	// ErrUnknownTeam is thrown when a MultiClient is asked for a team that was not added.
+	ErrUnknownTeam = errors.New("unknown team")
+
+	// ErrInvalidCacheSize is thrown when a MemoryCache is created with a size below 1.
+	ErrInvalidCacheSize = errors.New("cache size must be at least 1")
+
+	// ErrNoPruneAge is thrown when Client.Prune() is called with neither a positive OlderThan nor All set.
+	ErrNoPruneAge = errors.New("prune options need a positive OlderThan or All")
+
+	// ErrNoJournal is thrown when Client.Resume() is called without setting a journal first
+	ErrNoJournal = errors.New("no journal set, see Client.SetJournal()")
+
+	// ErrUnknownFields is thrown when strict decoding is enabled and a response contains fields unknown to the client.
+	ErrUnknownFields = errors.New("response contains unknown fields")
+
+	// ErrResponseTooLarge is thrown when a response body exceeds the limit set by Client.SetMaxResponseSize().
+	ErrResponseTooLarge = errors.New("response body too large")
+
+	// ErrUnexpectedContentType is thrown when a response is no JSON, like the HTML error page of a proxy.
+	ErrUnexpectedContentType = errors.New("unexpected content type")
 )
 
 
@@ -0,0 +1,227 @@
+package puppetmaster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jobFields is the plain Job without JSON methods, used to (un)marshal the known fields.
+type jobFields Job
+
+var jobType = reflect.TypeOf(Job{})
+
+// knownJobFields are the JSON field names of Job.
+var knownJobFields = func() map[string]bool {
+	known := map[string]bool{}
+	for name := range jsonFields(jobType) {
+		known[name] = true
+	}
+
+	return known
+}()
+
+// jsonFields returns the JSON field names of a struct type with the types of their fields.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = t.Field(i).Type
+		}
+	}
+
+	return fields
+}
+
+var jsonNull = []byte("null")
+
+// UnmarshalJSON decodes a job, keeping fields unknown to the client in Extra and recording whether the error was
+// sent, see ErrorPresence.
+func (j *Job) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), jsonNull) {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, (*jobFields)(j)); err != nil {
+		return err
+	}
+
+	rawError, ok := fields["error"]
+	switch {
+	case !ok:
+		j.ErrorPresence = FieldMissing
+	case bytes.Equal(rawError, jsonNull):
+		j.ErrorPresence = FieldNull
+	default:
+		j.ErrorPresence = FieldPresent
+	}
+
+	j.Extra = nil
+	for name, value := range fields {
+		if knownJobFields[name] {
+			continue
+		}
+
+		if j.Extra == nil {
+			j.Extra = map[string]json.RawMessage{}
+		}
+		j.Extra[name] = value
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes a job including the fields in Extra. An empty error is encoded as null or left out, as given by
+// ErrorPresence.
+func (j Job) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(jobFields(j))
+	if err != nil {
+		return nil, err
+	}
+
+	errorPresence := j.ErrorPresence
+	if j.Error != "" {
+		errorPresence = FieldPresent
+	}
+
+	if errorPresence == FieldPresent && len(j.Extra) == 0 {
+		return b, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	switch errorPresence {
+	case FieldNull:
+		fields["error"] = jsonNull
+	case FieldMissing:
+		delete(fields, "error")
+	}
+
+	for name, value := range j.Extra {
+		if !knownJobFields[name] {
+			fields[name] = value
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+// EnableStrictDecoding makes the client reject responses containing fields it does not know, with ErrUnknownFields,
+// to catch changes of the API in tests. Unknown fields of jobs and of their logs are checked as well as the envelopes
+// around them.
+func (c *Client) EnableStrictDecoding() {
+	c.strictDecoding = true
+}
+
+// decode decodes a response body into v, which is a *JobResponse or *JobPagination.
+func (c *Client) decode(res *http.Response, v interface{}) error {
+	if err := checkAPIVersion(res); err != nil {
+		return err
+	}
+
+	if err := checkContentType(res); err != nil {
+		return err
+	}
+
+	if !c.strictDecoding {
+		return json.NewDecoder(res.Body).Decode(v)
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	// an empty body is io.EOF, as without strict decoding, so callers can handle both modes alike
+	if len(bytes.TrimSpace(b)) == 0 {
+		return io.EOF
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return err
+	}
+
+	if unknown := unknownFields(b, reflect.TypeOf(v), ""); len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%w: %s", ErrUnknownFields, strings.Join(unknown, ", "))
+	}
+
+	var jobs []Job
+	switch value := v.(type) {
+	case *JobResponse:
+		jobs = []Job{value.Data}
+	case *JobPagination:
+		jobs = value.Jobs
+	}
+
+	for _, job := range jobs {
+		if len(job.Extra) == 0 {
+			continue
+		}
+
+		names := make([]string, 0, len(job.Extra))
+		for name := range job.Extra {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return fmt.Errorf("%w: job %s has %s", ErrUnknownFields, job.UUID, strings.Join(names, ", "))
+	}
+
+	return nil
+}
+
+// unknownFields returns the paths of all fields in the JSON value b that are unknown to the structs of type t. Unknown
+// fields of jobs themselves are skipped, they are kept in Extra, but their known fields like logs are checked.
+func unknownFields(b []byte, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var unknown []string
+	switch t.Kind() {
+	case reflect.Struct:
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(b, &values); err != nil {
+			return nil
+		}
+
+		known := jsonFields(t)
+		for name, value := range values {
+			fieldType, ok := known[name]
+			if !ok {
+				if t != jobType {
+					unknown = append(unknown, path+name)
+				}
+				continue
+			}
+
+			unknown = append(unknown, unknownFields(value, fieldType, path+name+".")...)
+		}
+	case reflect.Slice, reflect.Array:
+		var values []json.RawMessage
+		if err := json.Unmarshal(b, &values); err != nil {
+			return nil
+		}
+
+		for i, value := range values {
+			unknown = append(unknown, unknownFields(value, t.Elem(), fmt.Sprintf("%s[%d].", strings.TrimSuffix(path, "."), i))...)
+		}
+	}
+
+	return unknown
+}
@@ -0,0 +1,161 @@
+package puppetmaster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJob_UnmarshalJSON(t *testing.T) {
+	cases := []struct {
+		data             string
+		expError         string
+		expErrorPresence FieldPresence
+		expExtra         map[string]json.RawMessage
+	}{
+		{data: `{"uuid": "a", "error": null}`, expErrorPresence: FieldNull},
+		{data: `{"uuid": "a"}`, expErrorPresence: FieldMissing},
+		{data: `{"uuid": "a", "error": ""}`, expErrorPresence: FieldPresent},
+		{data: `{"uuid": "a", "error": "boom"}`, expError: "boom", expErrorPresence: FieldPresent},
+		{
+			data:     `{"uuid": "a", "error": "", "priority": 3, "tags": ["x"]}`,
+			expExtra: map[string]json.RawMessage{"priority": json.RawMessage(`3`), "tags": json.RawMessage(`["x"]`)},
+		},
+	}
+
+	for i, c := range cases {
+		job := &Job{}
+		if err := json.Unmarshal([]byte(c.data), job); err != nil {
+			t.Fatalf("case %d: failed to decode job: %v", i, err)
+		}
+
+		if job.UUID != "a" || job.Error != c.expError || job.ErrorPresence != c.expErrorPresence {
+			t.Errorf("case %d: Expected uuid a, error %q and presence %v, got %+v", i, c.expError, c.expErrorPresence, job)
+		}
+
+		if !reflect.DeepEqual(job.Extra, c.expExtra) {
+			t.Errorf("case %d: Expected extra %s, got %s", i, c.expExtra, job.Extra)
+		}
+	}
+}
+
+func TestJob_MarshalJSON(t *testing.T) {
+	cases := []struct {
+		job              *Job
+		exp              map[string]interface{}
+		expErrorPresence FieldPresence
+	}{
+		{job: &Job{UUID: "a"}, exp: map[string]interface{}{"error": ""}},
+		{job: &Job{UUID: "a", ErrorPresence: FieldNull}, exp: map[string]interface{}{"error": nil}, expErrorPresence: FieldNull},
+		{job: &Job{UUID: "a", ErrorPresence: FieldMissing}, exp: map[string]interface{}{}, expErrorPresence: FieldMissing},
+		{job: &Job{UUID: "a", Error: "boom", ErrorPresence: FieldNull}, exp: map[string]interface{}{"error": "boom"}},
+		{
+			job: &Job{UUID: "a", Extra: map[string]json.RawMessage{"priority": json.RawMessage(`3`), "uuid": json.RawMessage(`"b"`)}},
+			exp: map[string]interface{}{"error": "", "priority": 3.0},
+		},
+	}
+
+	for i, c := range cases {
+		b, err := json.Marshal(c.job)
+		if err != nil {
+			t.Fatalf("case %d: failed to encode job: %v", i, err)
+		}
+
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(b, &fields); err != nil {
+			t.Fatalf("case %d: failed to decode job: %v", i, err)
+		}
+
+		if fields["uuid"] != "a" {
+			t.Errorf("case %d: Expected known fields to win over extra fields, got uuid %v", i, fields["uuid"])
+		}
+
+		for k, v := range c.exp {
+			if got, ok := fields[k]; !ok || got != v {
+				t.Errorf("case %d: Expected %s to be %v, got %s", i, k, v, b)
+			}
+		}
+
+		decoded := &Job{}
+		if err := json.Unmarshal(b, decoded); err != nil {
+			t.Fatalf("case %d: failed to decode job: %v", i, err)
+		}
+
+		if _, ok := fields["error"]; ok != (c.expErrorPresence != FieldMissing) {
+			t.Errorf("case %d: Expected error field to be sent == %v, got %s", i, c.expErrorPresence != FieldMissing, b)
+		}
+
+		expExtra := len(c.exp)
+		if _, ok := c.exp["error"]; ok {
+			expExtra--
+		}
+
+		if len(decoded.Extra) != expExtra || decoded.ErrorPresence != c.expErrorPresence {
+			t.Errorf("case %d: Expected job to survive a round trip, got %+v", i, decoded)
+		}
+	}
+}
+
+func TestClient_StrictDecoding(t *testing.T) {
+	jobWithExtra := `{"data": {"uuid": "a", "status": "done", "priority": 3}}`
+
+	cases := []struct {
+		body   string
+		strict bool
+		expErr error
+	}{
+		{body: string(readTestData(t, "get-job-response.json")), strict: true},
+		{body: jobWithExtra, expErr: nil},
+		{body: jobWithExtra, strict: true, expErr: ErrUnknownFields},
+		{body: `{"data": {"uuid": "a"}, "debug": true}`, strict: true, expErr: ErrUnknownFields},
+		{body: `{"data": {"uuid": "a", "logs": [{"message": "x", "source": "page"}]}}`, expErr: nil},
+		{body: `{"data": {"uuid": "a", "logs": [{"message": "x", "source": "page"}]}}`, strict: true, expErr: ErrUnknownFields},
+		{body: "", expErr: io.EOF},
+		{body: " \n", strict: true, expErr: io.EOF},
+	}
+
+	for i, c := range cases {
+		tc := newTestClient(t, dumbHandler(200, strings.NewReader(c.body)))
+		if c.strict {
+			tc.client.EnableStrictDecoding()
+		}
+
+		job, err := tc.client.GetJob("a")
+		tc.server.Close()
+
+		if !errors.Is(err, c.expErr) {
+			t.Errorf("case %d: Expected error %v, got %v", i, c.expErr, err)
+		}
+
+		if err == nil && c.body == jobWithExtra && string(job.Extra["priority"]) != "3" {
+			t.Errorf("case %d: Expected unknown field to be kept, got %s", i, job.Extra)
+		}
+	}
+}
+
+func TestClient_StrictDecoding_List(t *testing.T) {
+	c := newTestClient(t, dumbHandler(200, bytes.NewReader(readTestData(t, "get-jobs-response.json"))))
+	defer c.server.Close()
+	c.client.EnableStrictDecoding()
+
+	jobs, err := c.client.GetJobs(1, 15)
+	if err != nil {
+		t.Fatalf("failed to list jobs: %v", err)
+	}
+
+	if jobs.Meta.To != 10 || jobs.Links.Next != "" || jobs.Jobs[0].ErrorPresence != FieldNull {
+		t.Errorf("Expected pagination and null errors to be decoded, got %+v", jobs)
+	}
+
+	tc := newTestClient(t, dumbHandler(200, strings.NewReader(`{"data": [], "links": {}, "meta": {"total": 0, "cursor": "x"}}`)))
+	defer tc.server.Close()
+	tc.client.EnableStrictDecoding()
+
+	if _, err := tc.client.GetJobs(1, 15); !errors.Is(err, ErrUnknownFields) || !strings.Contains(err.Error(), "meta.cursor") {
+		t.Errorf("Expected unknown nested field meta.cursor, got %v", err)
+	}
+}
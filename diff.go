@@ -0,0 +1,565 @@
+package puppetmaster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangeKind describes how a value changed between two jobs.
+type ChangeKind string
+
+// possible change kinds
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// Change is a single difference between two jobs found by Diff().
+type Change struct {
+	// Path points to the changed value in JSON path notation based on the JSON field names of Job, e.g.
+	// "results.items[2].price", "vars.url" or "logs[3]". Keys that are no identifiers are quoted, as in
+	// `results["content-type"]`. Indices of removed values refer to j1, all others to j2.
+	Path string      `json:"path"`
+	Kind ChangeKind  `json:"kind"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// String renders the change as a single line, prefixed with "+" for added, "-" for removed and "~" for modified
+// values.
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s: %s", c.Path, renderDiffValue(c.New))
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s: %s", c.Path, renderDiffValue(c.Old))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, renderDiffValue(c.Old), renderDiffValue(c.New))
+	}
+}
+
+// CompareOption configures Diff() and Job.EqualWith().
+type CompareOption func(*compareConfig)
+
+type compareConfig struct {
+	ignoreTimestamps bool
+	ignorePaths      []string
+	numeric          bool
+	timeTolerance    time.Duration
+	ignoreLogOrder   bool
+	ignoreLogLevel   bool
+}
+
+// IgnoreTimestamps ignores created_at, started_at, finished_at and the time of logs.
+func IgnoreTimestamps() CompareOption {
+	return func(c *compareConfig) {
+		c.ignoreTimestamps = true
+	}
+}
+
+// IgnorePaths ignores changes of the given paths and all values below them, e.g. "results.fetched_at".
+func IgnorePaths(paths ...string) CompareOption {
+	return func(c *compareConfig) {
+		c.ignorePaths = append(c.ignorePaths, paths...)
+	}
+}
+
+// IgnoreVolatile ignores all fields that differ between two runs of the same job: the UUID, the duration and all
+// timestamps.
+func IgnoreVolatile() CompareOption {
+	return func(c *compareConfig) {
+		c.ignoreTimestamps = true
+		c.ignorePaths = append(c.ignorePaths, "uuid", "duration")
+	}
+}
+
+// CompareNumbers compares numbers in results by value, so e.g. int 1 and the float64 1 decoded from JSON are equal,
+// also within typed slices and maps like []int.
+func CompareNumbers() CompareOption {
+	return func(c *compareConfig) {
+		c.numeric = true
+	}
+}
+
+// TimeTolerance treats timestamps, including the time of logs, as equal if they differ by at most tolerance.
+func TimeTolerance(tolerance time.Duration) CompareOption {
+	return func(c *compareConfig) {
+		c.timeTolerance = tolerance
+	}
+}
+
+// IgnoreLogOrder compares logs as a set of lines, so lines logged in a different order are not a change. Indices of
+// changed log lines refer to the logs sorted by message.
+func IgnoreLogOrder() CompareOption {
+	return func(c *compareConfig) {
+		c.ignoreLogOrder = true
+	}
+}
+
+// IgnoreLogLevel matches log lines by their message only.
+func IgnoreLogLevel() CompareOption {
+	return func(c *compareConfig) {
+		c.ignoreLogLevel = true
+	}
+}
+
+func (c *compareConfig) ignored(path string) bool {
+	for _, p := range c.ignorePaths {
+		if path == p || strings.HasPrefix(path, p+".") || strings.HasPrefix(path, p+"[") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Diff returns the differences between j1 and j2, e.g. two runs of the same script. Results are compared
+// recursively, logs are compared line by line, matching lines by level and message, so inserted or dropped lines
+// show up as added or removed instead of shifting all following lines. A nil job is compared like an empty one.
+func Diff(j1, j2 *Job, opts ...CompareOption) []Change {
+	config := &compareConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if j1 == nil {
+		j1 = &Job{}
+	}
+	if j2 == nil {
+		j2 = &Job{}
+	}
+
+	d := &differ{config: config}
+
+	d.compare("uuid", j1.UUID, j2.UUID)
+	d.compare("status", j1.Status, j2.Status)
+	d.compare("code", j1.Code, j2.Code)
+	d.compareStringMaps("vars", j1.Vars, j2.Vars)
+	d.compareStringMaps("modules", j1.Modules, j2.Modules)
+	d.compare("error", j1.Error, j2.Error)
+	d.compareValues("results", mapValue(j1.Results), mapValue(j2.Results))
+	d.compareLogs(j1.Logs, j2.Logs)
+
+	if !config.ignoreTimestamps {
+		d.compareTimes("created_at", &j1.CreatedAt, &j2.CreatedAt)
+		d.compareTimes("started_at", j1.StartedAt, j2.StartedAt)
+		d.compareTimes("finished_at", j1.FinishedAt, j2.FinishedAt)
+	}
+
+	d.compare("duration", j1.Duration, j2.Duration)
+	d.compareValues("extra", rawMapValue(j1.Extra), rawMapValue(j2.Extra))
+
+	return d.changes
+}
+
+type differ struct {
+	config  *compareConfig
+	changes []Change
+}
+
+func (d *differ) add(c Change) {
+	if !d.config.ignored(c.Path) {
+		d.changes = append(d.changes, c)
+	}
+}
+
+func (d *differ) compare(path string, v1, v2 interface{}) {
+	if v1 != v2 {
+		d.add(Change{Path: path, Kind: ChangeModified, Old: v1, New: v2})
+	}
+}
+
+func (d *differ) compareTimes(path string, t1, t2 *time.Time) {
+	switch {
+	case datesAreEqual(t1, t2):
+	case t1 != nil && t2 != nil && absDuration(t1.Sub(*t2)) <= d.config.timeTolerance:
+	case t1 == nil:
+		d.add(Change{Path: path, Kind: ChangeAdded, New: *t2})
+	case t2 == nil:
+		d.add(Change{Path: path, Kind: ChangeRemoved, Old: *t1})
+	default:
+		d.add(Change{Path: path, Kind: ChangeModified, Old: *t1, New: *t2})
+	}
+}
+
+func (d *differ) compareStringMaps(path string, m1, m2 map[string]string) {
+	for _, k := range unionKeys(m1, m2) {
+		v1, ok1 := m1[k]
+		v2, ok2 := m2[k]
+		p := joinPath(path, k)
+
+		switch {
+		case !ok1:
+			d.add(Change{Path: p, Kind: ChangeAdded, New: v2})
+		case !ok2:
+			d.add(Change{Path: p, Kind: ChangeRemoved, Old: v1})
+		default:
+			d.compare(p, v1, v2)
+		}
+	}
+}
+
+// compareValues compares decoded JSON values, descending into objects and arrays. Typed slices and maps, e.g. of
+// expectations built by hand, are compared like their decoded counterparts.
+func (d *differ) compareValues(path string, v1, v2 interface{}) {
+	v1, v2 = genericValue(v1), genericValue(v2)
+
+	if m1, ok := v1.(map[string]interface{}); ok {
+		if m2, ok := v2.(map[string]interface{}); ok {
+			for _, k := range unionKeys(m1, m2) {
+				c1, ok1 := m1[k]
+				c2, ok2 := m2[k]
+				p := joinPath(path, k)
+
+				switch {
+				case !ok1:
+					d.add(Change{Path: p, Kind: ChangeAdded, New: c2})
+				case !ok2:
+					d.add(Change{Path: p, Kind: ChangeRemoved, Old: c1})
+				default:
+					d.compareValues(p, c1, c2)
+				}
+			}
+			return
+		}
+	}
+
+	if s1, ok := v1.([]interface{}); ok {
+		if s2, ok := v2.([]interface{}); ok {
+			for i := 0; i < len(s1) || i < len(s2); i++ {
+				p := fmt.Sprintf("%s[%d]", path, i)
+
+				switch {
+				case i >= len(s1):
+					d.add(Change{Path: p, Kind: ChangeAdded, New: s2[i]})
+				case i >= len(s2):
+					d.add(Change{Path: p, Kind: ChangeRemoved, Old: s1[i]})
+				default:
+					d.compareValues(p, s1[i], s2[i])
+				}
+			}
+			return
+		}
+	}
+
+	if d.config.numeric {
+		if f1, ok := toFloat(v1); ok {
+			if f2, ok := toFloat(v2); ok && f1 == f2 {
+				return
+			}
+		}
+	}
+
+	if !reflect.DeepEqual(v1, v2) {
+		d.add(Change{Path: path, Kind: ChangeModified, Old: v1, New: v2})
+	}
+}
+
+// genericValue converts slices, arrays and maps with string keys of any type to []interface{} and
+// map[string]interface{}, as encoding/json decodes them. Other values, including []byte, are returned as they are.
+func genericValue(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, []interface{}, map[string]interface{}, []byte:
+		return v
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return []interface{}(nil)
+		}
+
+		s := make([]interface{}, rv.Len())
+		for i := range s {
+			s[i] = rv.Index(i).Interface()
+		}
+		return s
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+
+		if rv.IsNil() {
+			return map[string]interface{}(nil)
+		}
+
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = iter.Value().Interface()
+		}
+		return m
+	}
+
+	return v
+}
+
+// toFloat converts any number, including json.Number, to a float64.
+func toFloat(v interface{}) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+
+	return 0, false
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
+
+// compareLogs aligns both logs along their longest common subsequence of matching lines. Equal lines at the start
+// and end are matched directly, so mostly equal logs are compared in linear time; the rest is aligned in linear space.
+func (d *differ) compareLogs(l1, l2 []Log) {
+	if d.config.ignoreLogOrder {
+		l1, l2 = d.sortedLogs(l1), d.sortedLogs(l2)
+	}
+
+	a := &logAligner{l1: l1, l2: l2, ignoreLevel: d.config.ignoreLogLevel}
+
+	start := 0
+	for start < len(l1) && start < len(l2) && a.same(start, start) {
+		start++
+	}
+
+	end1, end2 := len(l1), len(l2)
+	for end1 > start && end2 > start && a.same(end1-1, end2-1) {
+		end1--
+		end2--
+	}
+
+	var matches [][2]int
+	for k := 0; k < start; k++ {
+		matches = append(matches, [2]int{k, k})
+	}
+	matches = a.align(matches, start, end1, start, end2)
+	for k := 0; end1+k < len(l1); k++ {
+		matches = append(matches, [2]int{end1 + k, end2 + k})
+	}
+
+	i, j := 0, 0
+	for _, m := range append(matches, [2]int{len(l1), len(l2)}) {
+		for ; i < m[0]; i++ {
+			d.add(Change{Path: fmt.Sprintf("logs[%d]", i), Kind: ChangeRemoved, Old: l1[i]})
+		}
+		for ; j < m[1]; j++ {
+			d.add(Change{Path: fmt.Sprintf("logs[%d]", j), Kind: ChangeAdded, New: l2[j]})
+		}
+
+		if i == len(l1) && j == len(l2) {
+			break
+		}
+
+		if !d.config.ignoreTimestamps {
+			d.compareTimes(fmt.Sprintf("logs[%d].time", j), &l1[i].Time, &l2[j].Time)
+		}
+		i++
+		j++
+	}
+}
+
+// logAligner finds the longest common subsequence of matching log lines with Hirschberg's algorithm, which only
+// keeps two rows of the dynamic programming table instead of all of them.
+type logAligner struct {
+	l1, l2      []Log
+	ignoreLevel bool
+}
+
+func (a *logAligner) same(i, j int) bool {
+	return (a.ignoreLevel || a.l1[i].Level == a.l2[j].Level) && a.l1[i].Message == a.l2[j].Message
+}
+
+// align appends the matching pairs of l1[i0:i1] and l2[j0:j1] to matches, in order.
+func (a *logAligner) align(matches [][2]int, i0, i1, j0, j1 int) [][2]int {
+	if i0 == i1 || j0 == j1 {
+		return matches
+	}
+
+	if i1-i0 == 1 {
+		for j := j0; j < j1; j++ {
+			if a.same(i0, j) {
+				return append(matches, [2]int{i0, j})
+			}
+		}
+		return matches
+	}
+
+	mid := (i0 + i1) / 2
+	forward := a.lengths(i0, mid, j0, j1, false)
+	backward := a.lengths(mid, i1, j0, j1, true)
+
+	split, best := 0, -1
+	for k := 0; k <= j1-j0; k++ {
+		if n := forward[k] + backward[k]; n > best {
+			split, best = k, n
+		}
+	}
+
+	matches = a.align(matches, i0, mid, j0, j0+split)
+	return a.align(matches, mid, i1, j0+split, j1)
+}
+
+// lengths returns the lengths of the longest common subsequences of l1[i0:i1] with every prefix l2[j0:j0+k], or with
+// every suffix l2[j0+k:j1] if reverse is set, indexed by k.
+func (a *logAligner) lengths(i0, i1, j0, j1 int, reverse bool) []int {
+	n := j1 - j0
+	prev, cur := make([]int, n+1), make([]int, n+1)
+
+	for x := 0; x < i1-i0; x++ {
+		for k := 1; k <= n; k++ {
+			var match bool
+			if reverse {
+				match = a.same(i1-1-x, j1-k)
+			} else {
+				match = a.same(i0+x, j0+k-1)
+			}
+
+			switch {
+			case match:
+				cur[k] = prev[k-1] + 1
+			case prev[k] >= cur[k-1]:
+				cur[k] = prev[k]
+			default:
+				cur[k] = cur[k-1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	if reverse {
+		// prev[k] belongs to the suffix of length k, which starts at j1-k
+		for l, r := 0, n; l < r; l, r = l+1, r-1 {
+			prev[l], prev[r] = prev[r], prev[l]
+		}
+	}
+
+	return prev
+}
+
+// sortedLogs returns a sorted copy of logs, so equal sets of lines align regardless of their order.
+func (d *differ) sortedLogs(logs []Log) []Log {
+	sorted := append([]Log{}, logs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Message != sorted[j].Message {
+			return sorted[i].Message < sorted[j].Message
+		}
+
+		if !d.config.ignoreLogLevel && sorted[i].Level != sorted[j].Level {
+			return sorted[i].Level < sorted[j].Level
+		}
+
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	return sorted
+}
+
+// mapValue treats missing results like empty ones, so only the keys show up as changes.
+func mapValue(m map[string]interface{}) interface{} {
+	if m == nil {
+		return map[string]interface{}{}
+	}
+
+	return m
+}
+
+// rawMapValue decodes the raw values, keeping values that are no valid JSON as string.
+func rawMapValue(m map[string]json.RawMessage) interface{} {
+	values := make(map[string]interface{}, len(m))
+	for k, raw := range m {
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			v = string(raw)
+		}
+		values[k] = v
+	}
+
+	return values
+}
+
+func unionKeys[V any](m1, m2 map[string]V) []string {
+	keys := make([]string, 0, len(m1)+len(m2))
+	for k := range m1 {
+		keys = append(keys, k)
+	}
+	for k := range m2 {
+		if _, ok := m1[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func joinPath(path, key string) string {
+	if identifierPattern.MatchString(key) {
+		return path + "." + key
+	}
+
+	return path + "[" + strconv.Quote(key) + "]"
+}
+
+func renderDiffValue(v interface{}) string {
+	switch value := v.(type) {
+	case Log:
+		return fmt.Sprintf("%s %s", value.Level, strconv.Quote(value.Message))
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return string(b)
+}
+
+// WriteChangesText writes the changes to w, one line per change as rendered by Change.String().
+func WriteChangesText(w io.Writer, changes []Change) error {
+	for _, c := range changes {
+		if _, err := fmt.Fprintln(w, c.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteChangesJSON writes the changes to w as JSON lines.
+func WriteChangesJSON(w io.Writer, changes []Change) error {
+	enc := json.NewEncoder(w)
+	for _, c := range changes {
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
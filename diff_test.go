@@ -0,0 +1,185 @@
+package puppetmaster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Minute)
+
+	cases := []struct {
+		j1, j2 *Job
+		opts   []CompareOption
+		exp    []string
+	}{
+		{
+			j1:  &Job{Status: StatusDone, Results: map[string]interface{}{"title": "foo"}},
+			j2:  &Job{Status: StatusDone, Results: map[string]interface{}{"title": "foo"}},
+			exp: nil,
+		},
+		{
+			j1:  &Job{UUID: "a", Status: StatusQueued},
+			j2:  &Job{UUID: "b", Status: StatusDone},
+			exp: []string{`~ uuid: "a" -> "b"`, `~ status: "queued" -> "done"`},
+		},
+		{
+			j1:   &Job{UUID: "a", Duration: 12, CreatedAt: now, FinishedAt: &now},
+			j2:   &Job{UUID: "b", Duration: 15, CreatedAt: later},
+			opts: []CompareOption{IgnoreVolatile()},
+			exp:  nil,
+		},
+		{
+			j1:  &Job{CreatedAt: now, FinishedAt: &now},
+			j2:  &Job{CreatedAt: now},
+			exp: []string{"- finished_at: " + now.Format(time.RFC3339Nano)},
+		},
+		{
+			j1:  &Job{Vars: map[string]string{"url": "a", "gone": "x"}},
+			j2:  &Job{Vars: map[string]string{"url": "b", "new-var": "y"}},
+			exp: []string{`- vars.gone: "x"`, `+ vars["new-var"]: "y"`, `~ vars.url: "a" -> "b"`},
+		},
+		{
+			j1: &Job{Results: map[string]interface{}{
+				"items":   []interface{}{map[string]interface{}{"price": 1.0}, map[string]interface{}{"price": 2.0}},
+				"fetched": "today",
+			}},
+			j2: &Job{Results: map[string]interface{}{
+				"items": []interface{}{map[string]interface{}{"price": 1.0}, map[string]interface{}{"price": 3.0}, "extra"},
+				"meta":  map[string]interface{}{"pages": 2.0},
+			}},
+			exp: []string{
+				`- results.fetched: "today"`,
+				`~ results.items[1].price: 2 -> 3`,
+				`+ results.items[2]: "extra"`,
+				`+ results.meta: {"pages":2}`,
+			},
+		},
+		{
+			j1:   &Job{Results: map[string]interface{}{"ids": []int{1, 2, 4}}},
+			j2:   &Job{Results: map[string]interface{}{"ids": []interface{}{1.0, 2.0, 3.0}}},
+			opts: []CompareOption{CompareNumbers()},
+			exp:  []string{`~ results.ids[2]: 4 -> 3`},
+		},
+		{
+			j1:   &Job{Results: map[string]interface{}{"items": []interface{}{1.0}, "fetched": "today"}},
+			j2:   &Job{Results: map[string]interface{}{"items": []interface{}{2.0}, "fetched": "tomorrow"}},
+			opts: []CompareOption{IgnorePaths("results.fetched", "results.items")},
+			exp:  nil,
+		},
+		{
+			j1: &Job{Logs: []Log{
+				{Time: now, Level: LevelInfo, Message: "start"},
+				{Time: now, Level: LevelInfo, Message: "old step"},
+				{Time: now, Level: LevelInfo, Message: "done"},
+			}},
+			j2: &Job{Logs: []Log{
+				{Time: now, Level: LevelInfo, Message: "start"},
+				{Time: now, Level: LevelWarn, Message: "new step"},
+				{Time: later, Level: LevelInfo, Message: "done"},
+			}},
+			exp: []string{
+				`- logs[1]: INFO "old step"`,
+				`+ logs[1]: WARN "new step"`,
+				"~ logs[2].time: " + now.Format(time.RFC3339Nano) + " -> " + later.Format(time.RFC3339Nano),
+			},
+		},
+		{
+			j1:  &Job{Extra: map[string]json.RawMessage{"priority": json.RawMessage(`1`)}},
+			j2:  &Job{Extra: map[string]json.RawMessage{"priority": json.RawMessage(`2`), "tags": json.RawMessage(`["a"]`)}},
+			exp: []string{`~ extra.priority: 1 -> 2`, `+ extra.tags: ["a"]`},
+		},
+		{
+			j1: &Job{Logs: []Log{
+				{Level: LevelInfo, Message: "a"},
+				{Level: LevelInfo, Message: "b"},
+				{Level: LevelInfo, Message: "c"},
+				{Level: LevelInfo, Message: "d"},
+				{Level: LevelInfo, Message: "e"},
+			}},
+			j2: &Job{Logs: []Log{
+				{Level: LevelInfo, Message: "a"},
+				{Level: LevelInfo, Message: "c"},
+				{Level: LevelInfo, Message: "x"},
+				{Level: LevelInfo, Message: "d"},
+				{Level: LevelInfo, Message: "b"},
+				{Level: LevelInfo, Message: "e"},
+			}},
+			exp: []string{`- logs[1]: INFO "b"`, `+ logs[2]: INFO "x"`, `+ logs[4]: INFO "b"`},
+		},
+		{
+			j1:  nil,
+			j2:  &Job{Status: StatusDone},
+			exp: []string{`~ status: "" -> "done"`},
+		},
+	}
+
+	for i, c := range cases {
+		changes := Diff(c.j1, c.j2, c.opts...)
+
+		var got []string
+		for _, change := range changes {
+			got = append(got, change.String())
+		}
+
+		if strings.Join(got, "\n") != strings.Join(c.exp, "\n") {
+			t.Errorf("case %d: Expected changes\n%v\ngot\n%v", i, strings.Join(c.exp, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestDiff_LongLogs(t *testing.T) {
+	var l1, l2 []Log
+	for i := 0; i < 50000; i++ {
+		l := Log{Level: LevelInfo, Message: fmt.Sprintf("line %d", i)}
+		l1 = append(l1, l)
+		l2 = append(l2, l)
+	}
+	l2[25000].Message = "changed"
+
+	changes := Diff(&Job{Logs: l1}, &Job{Logs: l2})
+	if len(changes) != 2 || changes[0].Kind != ChangeRemoved || changes[1].Path != "logs[25000]" {
+		t.Errorf("Expected the changed line only, got %v", changes)
+	}
+}
+
+func TestWriteChanges(t *testing.T) {
+	changes := Diff(&Job{Status: StatusQueued}, &Job{Status: StatusDone, Error: "boom"})
+
+	text := &bytes.Buffer{}
+	if err := WriteChangesText(text, changes); err != nil {
+		t.Fatalf("failed to write text: %v", err)
+	}
+
+	exp := "~ status: \"queued\" -> \"done\"\n~ error: \"\" -> \"boom\"\n"
+	if text.String() != exp {
+		t.Errorf("Expected text %q, got %q", exp, text.String())
+	}
+
+	out := &bytes.Buffer{}
+	if err := WriteChangesJSON(out, changes); err != nil {
+		t.Fatalf("failed to write json: %v", err)
+	}
+
+	// empty values are still written, so a change to or from them can be told apart from a missing value
+	if !strings.Contains(out.String(), `"old":""`) {
+		t.Errorf("Expected empty old error to be written, got %s", out)
+	}
+
+	dec := json.NewDecoder(out)
+	for i := range changes {
+		c := Change{}
+		if err := dec.Decode(&c); err != nil {
+			t.Fatalf("failed to decode change %d: %v", i, err)
+		}
+
+		if c.Path != changes[i].Path || c.Kind != ChangeModified {
+			t.Errorf("Expected change %v, got %v", changes[i], c)
+		}
+	}
+}
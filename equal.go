@@ -5,8 +5,13 @@ import (
 	"time"
 )
 
-// Equal returns true when both given Jobs are equal
+// Equal returns true when both given Jobs are equal, ignoring CreatedAt and Duration. Its signature makes go-cmp use
+// it to compare jobs; use EqualWith() for more control.
 func (j *Job) Equal(j2 *Job) bool {
+	if j == nil || j2 == nil {
+		return j == j2
+	}
+
 	return j.UUID == j2.UUID &&
 		j.Status == j2.Status &&
 		j.Code == j2.Code &&
@@ -30,3 +35,13 @@ func datesAreEqual(t1 *time.Time, t2 *time.Time) bool {
 
 	return (*t1).Equal(*t2)
 }
+
+// EqualWith returns true when Diff() finds no changes between both jobs with the given options. Unlike Equal, it
+// compares all fields including CreatedAt and Duration unless they are ignored, e.g. by IgnoreVolatile().
+func (j *Job) EqualWith(j2 *Job, opts ...CompareOption) bool {
+	if j == nil || j2 == nil {
+		return j == j2
+	}
+
+	return len(Diff(j, j2, opts...)) == 0
+}
@@ -185,3 +185,120 @@ func TestDatesAreEqual(t *testing.T) {
 		}
 	}
 }
+
+func TestEqual_Nil(t *testing.T) {
+	var nilJob *Job
+
+	if !nilJob.Equal(nil) {
+		t.Error("Expected nil jobs to be equal")
+	}
+
+	if nilJob.Equal(&Job{}) || (&Job{}).Equal(nil) {
+		t.Error("Expected nil and non-nil jobs to differ")
+	}
+}
+
+func TestEqualWith(t *testing.T) {
+	now := time.Now()
+	later := now.Add(500 * time.Millisecond)
+
+	cases := []struct {
+		j1, j2 *Job
+		opts   []CompareOption
+		equal  bool
+	}{
+		{
+			j1:    &Job{Results: map[string]interface{}{"count": 1, "items": []interface{}{int64(2)}}},
+			j2:    &Job{Results: map[string]interface{}{"count": 1.0, "items": []interface{}{2.0}}},
+			equal: false,
+		},
+		{
+			j1:    &Job{Results: map[string]interface{}{"count": 1, "items": []interface{}{int64(2)}}},
+			j2:    &Job{Results: map[string]interface{}{"count": 1.0, "items": []interface{}{2.0}}},
+			opts:  []CompareOption{CompareNumbers()},
+			equal: true,
+		},
+		{
+			j1:    &Job{Results: map[string]interface{}{"ids": []int{1, 2}, "m": map[string]int{"n": 3}}},
+			j2:    &Job{Results: map[string]interface{}{"ids": []interface{}{1.0, 2.0}, "m": map[string]interface{}{"n": 3.0}}},
+			opts:  []CompareOption{CompareNumbers()},
+			equal: true,
+		},
+		{
+			j1:    &Job{Results: map[string]interface{}{"ids": []int{1, 2}}},
+			j2:    &Job{Results: map[string]interface{}{"ids": []interface{}{1.0, 3.0}}},
+			opts:  []CompareOption{CompareNumbers()},
+			equal: false,
+		},
+		{
+			j1:    &Job{Results: map[string]interface{}{"count": 1}},
+			j2:    &Job{Results: map[string]interface{}{"count": 1.5}},
+			opts:  []CompareOption{CompareNumbers()},
+			equal: false,
+		},
+		{
+			j1:    &Job{CreatedAt: now, Duration: 1},
+			j2:    &Job{CreatedAt: now, Duration: 2},
+			equal: false,
+		},
+		{
+			j1:    &Job{CreatedAt: now, StartedAt: &now},
+			j2:    &Job{CreatedAt: later, StartedAt: &later},
+			equal: false,
+		},
+		{
+			j1:    &Job{CreatedAt: now, StartedAt: &now, Logs: []Log{{Time: now, Message: "a"}}},
+			j2:    &Job{CreatedAt: later, StartedAt: &later, Logs: []Log{{Time: later, Message: "a"}}},
+			opts:  []CompareOption{TimeTolerance(time.Second)},
+			equal: true,
+		},
+		{
+			j1:    &Job{StartedAt: &now},
+			j2:    &Job{},
+			opts:  []CompareOption{TimeTolerance(time.Second)},
+			equal: false,
+		},
+		{
+			j1:    &Job{Logs: []Log{{Message: "a"}, {Message: "b"}}},
+			j2:    &Job{Logs: []Log{{Message: "b"}, {Message: "a"}}},
+			equal: false,
+		},
+		{
+			j1:    &Job{Logs: []Log{{Message: "a"}, {Message: "b"}}},
+			j2:    &Job{Logs: []Log{{Message: "b"}, {Message: "a"}}},
+			opts:  []CompareOption{IgnoreLogOrder()},
+			equal: true,
+		},
+		{
+			j1:    &Job{Logs: []Log{{Level: LevelInfo, Message: "a"}}},
+			j2:    &Job{Logs: []Log{{Level: LevelWarn, Message: "a"}}},
+			equal: false,
+		},
+		{
+			j1:    &Job{Logs: []Log{{Level: LevelInfo, Message: "a"}, {Level: LevelDebug, Message: "b"}}},
+			j2:    &Job{Logs: []Log{{Level: LevelError, Message: "b"}, {Level: LevelWarn, Message: "a"}}},
+			opts:  []CompareOption{IgnoreLogLevel(), IgnoreLogOrder()},
+			equal: true,
+		},
+		{
+			j1:    &Job{UUID: "a", Results: map[string]interface{}{"fetched_at": "now"}},
+			j2:    &Job{UUID: "b", Results: map[string]interface{}{"fetched_at": "then"}},
+			opts:  []CompareOption{IgnorePaths("uuid", "results.fetched_at")},
+			equal: true,
+		},
+	}
+
+	for i, c := range cases {
+		res1 := c.j1.EqualWith(c.j2, c.opts...)
+		res2 := c.j2.EqualWith(c.j1, c.opts...)
+
+		t.Logf("Case %d, res1=%v res2=%v, exp=%v", i, res1, res2, c.equal)
+		if res1 != c.equal {
+			t.Errorf("Case %d: Expected j1.EqualWith(j2) == %v, but got %v", i, c.equal, res1)
+		}
+
+		if res2 != c.equal {
+			t.Errorf("Case %d: Expected j2.EqualWith(j1) == %v, but got %v", i, c.equal, res2)
+		}
+	}
+}
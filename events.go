@@ -0,0 +1,64 @@
+package puppetmaster
+
+// JobEvent names a transition in the lifecycle of a job.
+type JobEvent string
+
+// possible job events
+const (
+	// EventCreated fires when the job was accepted by the puppet master.
+	EventCreated JobEvent = "created"
+	// EventQueued fires when the job was put into the execution queue.
+	EventQueued JobEvent = "queued"
+	// EventStarted fires when an executor picked up the job.
+	EventStarted JobEvent = "started"
+	// EventSucceeded fires when the job is done without an error.
+	EventSucceeded JobEvent = "succeeded"
+	// EventFailed fires when the job is done with an error.
+	EventFailed JobEvent = "failed"
+	// EventCancelled fires when the job was cancelled before it was done.
+	EventCancelled JobEvent = "cancelled"
+)
+
+// StatusChange is passed to SyncOptions.OnStatusChange for every lifecycle transition of a job.
+type StatusChange struct {
+	Event JobEvent
+	// Previous is the last snapshot of the job before the transition, nil for the first one.
+	Previous *Job
+	// Current is the snapshot of the job that caused the transition.
+	Current *Job
+}
+
+// jobEvents returns the transitions between two snapshots of a job in the order they happened. A single poll may
+// cover several transitions, e.g. a job that got queued, started and finished in between.
+func jobEvents(previous, current *Job) []JobEvent {
+	if previous == nil {
+		previous = &Job{}
+	}
+
+	var events []JobEvent
+	if current.Status == StatusCreated && previous.Status != StatusCreated {
+		events = append(events, EventCreated)
+	}
+
+	if current.Status == StatusQueued && previous.Status != StatusQueued {
+		events = append(events, EventQueued)
+	}
+
+	if previous.StartedAt == nil && current.StartedAt != nil {
+		events = append(events, EventStarted)
+	}
+
+	if current.Status == StatusDone && previous.Status != StatusDone {
+		if current.Error != "" {
+			events = append(events, EventFailed)
+		} else {
+			events = append(events, EventSucceeded)
+		}
+	}
+
+	if current.Status == StatusCancelled && previous.Status != StatusCancelled {
+		events = append(events, EventCancelled)
+	}
+
+	return events
+}
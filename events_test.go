@@ -0,0 +1,84 @@
+package puppetmaster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJobEvents(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		previous, current *Job
+		events            []JobEvent
+	}{
+		{previous: nil, current: &Job{Status: StatusCreated}, events: []JobEvent{EventCreated}},
+		{previous: &Job{Status: StatusCreated}, current: &Job{Status: StatusCreated}, events: nil},
+		{previous: &Job{Status: StatusCreated}, current: &Job{Status: StatusQueued}, events: []JobEvent{EventQueued}},
+		{
+			previous: &Job{Status: StatusQueued},
+			current:  &Job{Status: StatusQueued, StartedAt: &now},
+			events:   []JobEvent{EventStarted},
+		},
+		{
+			previous: &Job{Status: StatusQueued, StartedAt: &now},
+			current:  &Job{Status: StatusDone, StartedAt: &now},
+			events:   []JobEvent{EventSucceeded},
+		},
+		{
+			previous: &Job{Status: StatusCreated},
+			current:  &Job{Status: StatusDone, StartedAt: &now, Error: "failed"},
+			events:   []JobEvent{EventStarted, EventFailed},
+		},
+	}
+
+	for i, c := range cases {
+		events := jobEvents(c.previous, c.current)
+		if !reflect.DeepEqual(events, c.events) {
+			t.Errorf("case %d: Expected events %v, got %v", i, c.events, events)
+		}
+	}
+}
+
+func TestClient_ExecuteSyncOnStatusChange(t *testing.T) {
+	now := time.Now()
+	jobs := []Job{
+		{UUID: "uuid", Status: StatusCreated},
+		{UUID: "uuid", Status: StatusQueued},
+		{UUID: "uuid", Status: StatusQueued, StartedAt: &now},
+		{UUID: "uuid", Status: StatusQueued, StartedAt: &now},
+		{UUID: "uuid", Status: StatusDone, StartedAt: &now},
+	}
+	c := newTestClient(t, sequenceHandler(t, jobs...))
+	c.client.SetSyncSleepMs(1)
+
+	var changes []StatusChange
+	_, err := c.client.ExecuteSyncWithOptions(context.Background(), &JobRequest{Code: "test"}, &SyncOptions{
+		OnStatusChange: func(change StatusChange) {
+			changes = append(changes, change)
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to execute job: %v", err)
+	}
+
+	exp := []JobEvent{EventCreated, EventQueued, EventStarted, EventSucceeded}
+	if len(changes) != len(exp) {
+		t.Fatalf("Expected %d changes, got %+v", len(exp), changes)
+	}
+
+	for i, change := range changes {
+		if change.Event != exp[i] {
+			t.Errorf("change %d: Expected event %v, got %v", i, exp[i], change.Event)
+		}
+	}
+
+	if changes[0].Previous != nil {
+		t.Error("Expected the first change to have no previous job")
+	}
+
+	if changes[3].Previous.Status != StatusQueued || changes[3].Current.Status != StatusDone {
+		t.Errorf("Unexpected snapshots for last change: %+v", changes[3])
+	}
+}
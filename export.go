@@ -0,0 +1,261 @@
+package puppetmaster
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultExportFields are the fields exported if ExportOptions.Fields is empty.
+var DefaultExportFields = []string{
+	"uuid", "status", "code", "error", "created_at", "started_at", "finished_at", "duration", "results",
+}
+
+// ExportOptions selects the jobs and fields written by ExportCSV() and ExportJSONL().
+type ExportOptions struct {
+	// Fields are the exported fields: the JSON field names of Job, like "uuid" or "results", or a dot separated path
+	// into vars, modules or results, like "results.items.0.price". The path "results.*" is expanded to one field per
+	// leaf value of the results of the first exported job, so the results can be flattened into columns without
+	// listing them; results of later jobs with other keys are not exported then. If empty, DefaultExportFields are
+	// exported, except for ExportJSONL, which writes whole jobs then.
+	Fields []string
+	// CreatedFrom and CreatedTo, if set, only export jobs created within this range, both inclusive.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+func (o *ExportOptions) selects(job *Job) bool {
+	if !o.CreatedFrom.IsZero() && job.CreatedAt.Before(o.CreatedFrom) {
+		return false
+	}
+
+	return o.CreatedTo.IsZero() || !job.CreatedAt.After(o.CreatedTo)
+}
+
+// fields returns the exported fields, expanding "results.*" with the given job.
+func (o *ExportOptions) fields(job *Job) []string {
+	fields := o.Fields
+	if len(fields) == 0 {
+		fields = DefaultExportFields
+	}
+
+	var expanded []string
+	for _, f := range fields {
+		if f != "results.*" {
+			expanded = append(expanded, f)
+			continue
+		}
+
+		var leaves []string
+		collectLeaves("results", job.Results, &leaves)
+		sort.Strings(leaves)
+		expanded = append(expanded, leaves...)
+	}
+
+	return expanded
+}
+
+func collectLeaves(path string, v interface{}, leaves *[]string) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, child := range value {
+			collectLeaves(path+"."+k, child, leaves)
+		}
+	case []interface{}:
+		for i, child := range value {
+			collectLeaves(path+"."+strconv.Itoa(i), child, leaves)
+		}
+	default:
+		*leaves = append(*leaves, path)
+	}
+}
+
+// ExportCSV writes the jobs of the iterator as CSV with a header line, one row per job, and returns the number of
+// exported jobs. Jobs are written while iterating, so exports are not limited by memory.
+func ExportCSV(w io.Writer, jobs *JobIterator, opts *ExportOptions) (int, error) {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+
+	cw := csv.NewWriter(w)
+	var fields []string
+	headerWritten := false
+	n := 0
+
+	for jobs.Next() {
+		job := jobs.Job()
+		if !opts.selects(job) {
+			continue
+		}
+
+		// "results.*" alone may expand to no fields at all, so the header is tracked on its own
+		if !headerWritten {
+			fields = opts.fields(job)
+			if err := cw.Write(fields); err != nil {
+				return n, err
+			}
+			headerWritten = true
+		}
+
+		row := make([]string, len(fields))
+		for i, f := range fields {
+			row[i] = csvValue(jobField(job, f))
+		}
+
+		if err := cw.Write(row); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	// write the header for empty exports as well
+	if !headerWritten && jobs.Err() == nil {
+		if err := cw.Write(opts.fields(&Job{})); err != nil {
+			return n, err
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return n, err
+	}
+
+	return n, jobs.Err()
+}
+
+// ExportJSONL writes the jobs of the iterator as JSON lines and returns the number of exported jobs. With fields
+// selected, every line is an object of the selected fields, otherwise the whole job.
+func ExportJSONL(w io.Writer, jobs *JobIterator, opts *ExportOptions) (int, error) {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+
+	enc := json.NewEncoder(w)
+	var fields []string
+	expanded := false
+	n := 0
+
+	for jobs.Next() {
+		job := jobs.Job()
+		if !opts.selects(job) {
+			continue
+		}
+
+		var line interface{} = job
+		if len(opts.Fields) > 0 {
+			if !expanded {
+				fields = opts.fields(job)
+				expanded = true
+			}
+
+			values := make(map[string]interface{}, len(fields))
+			for _, f := range fields {
+				values[f] = jobField(job, f)
+			}
+			line = values
+		}
+
+		if err := enc.Encode(line); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, jobs.Err()
+}
+
+// jobField returns the value of the field, or nil if the job has no such value.
+func jobField(job *Job, field string) interface{} {
+	path := strings.Split(field, ".")
+
+	var root interface{}
+	switch path[0] {
+	case "uuid":
+		root = job.UUID
+	case "status":
+		root = job.Status
+	case "code":
+		root = job.Code
+	case "error":
+		root = job.Error
+	case "created_at":
+		root = job.CreatedAt
+	case "started_at":
+		if job.StartedAt != nil {
+			root = *job.StartedAt
+		}
+	case "finished_at":
+		if job.FinishedAt != nil {
+			root = *job.FinishedAt
+		}
+	case "duration":
+		root = job.Duration
+	case "logs":
+		root = job.Logs
+	case "vars":
+		root = stringMapValue(job.Vars)
+	case "modules":
+		root = stringMapValue(job.Modules)
+	case "results":
+		if job.Results != nil {
+			root = job.Results
+		}
+	}
+
+	return lookupPath(root, path[1:])
+}
+
+func stringMapValue(m map[string]string) map[string]interface{} {
+	values := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		values[k] = v
+	}
+
+	return values
+}
+
+func lookupPath(v interface{}, path []string) interface{} {
+	for _, key := range path {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			v = value[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(value) {
+				return nil
+			}
+			v = value[i]
+		default:
+			return nil
+		}
+	}
+
+	return v
+}
+
+func csvValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case int, bool:
+		return fmt.Sprint(value)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
@@ -0,0 +1,175 @@
+package puppetmaster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pagedHandler serves the given jobs in pages of two.
+func pagedHandler(t *testing.T, jobs []Job) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+		lastPage := (len(jobs) + 1) / 2
+
+		from, to := (page-1)*2, page*2
+		if from > len(jobs) {
+			from = len(jobs)
+		}
+		if to > len(jobs) {
+			to = len(jobs)
+		}
+
+		res := &JobPagination{Jobs: jobs[from:to], Meta: PaginationMeta{CurrentPage: uint(page), LastPage: uint(lastPage)}}
+		if err := json.NewEncoder(rw).Encode(res); err != nil {
+			t.Errorf("failed to encode jobs: %v", err)
+		}
+	})
+}
+
+func exportTestJobs() []Job {
+	day := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	return []Job{
+		{UUID: "a", Status: StatusDone, CreatedAt: day, Results: map[string]interface{}{"price": 1.5, "tags": []interface{}{"x"}}},
+		{UUID: "b", Status: StatusDone, CreatedAt: day.AddDate(0, 0, 1), Results: map[string]interface{}{"price": 2.0, "note": "a, b"}},
+		{UUID: "c", Status: StatusQueued, CreatedAt: day.AddDate(0, 0, 2), Vars: map[string]string{"page": "https://example.com"}},
+	}
+}
+
+func TestJobIterator(t *testing.T) {
+	c := newTestClient(t, pagedHandler(t, exportTestJobs()))
+	defer c.server.Close()
+
+	var uuids []string
+	jobs := c.client.IterateJobs(context.Background(), "", 2)
+	for jobs.Next() {
+		uuids = append(uuids, jobs.Job().UUID)
+	}
+
+	if err := jobs.Err(); err != nil {
+		t.Fatalf("failed to iterate jobs: %v", err)
+	}
+
+	if strings.Join(uuids, ",") != "a,b,c" {
+		t.Errorf("Expected jobs a,b,c, got %v", uuids)
+	}
+
+	failing := newTestClient(t, dumbHandler(500, nil))
+	defer failing.server.Close()
+
+	jobs = failing.client.IterateJobs(context.Background(), "", 2)
+	if jobs.Next() || jobs.Err() == nil {
+		t.Error("Expected failing listing to stop the iteration with an error")
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	day := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		opts *ExportOptions
+		exp  string
+	}{
+		{
+			opts: &ExportOptions{Fields: []string{"uuid", "status", "results.price", "vars.page"}},
+			exp:  "uuid,status,results.price,vars.page\na,done,1.5,\nb,done,2,\nc,queued,,https://example.com\n",
+		},
+		{
+			opts: &ExportOptions{Fields: []string{"uuid", "results.*"}, CreatedFrom: day.AddDate(0, 0, 1)},
+			exp:  "uuid,results.note,results.price\nb,\"a, b\",2\nc,,\n",
+		},
+		{
+			opts: &ExportOptions{Fields: []string{"uuid", "results"}, CreatedTo: day.AddDate(0, 0, 1)},
+			exp:  "uuid,results\na,\"{\"\"price\"\":1.5,\"\"tags\"\":[\"\"x\"\"]}\"\n",
+		},
+		{
+			opts: &ExportOptions{Fields: []string{"uuid"}, CreatedFrom: day.AddDate(1, 0, 0)},
+			exp:  "uuid\n",
+		},
+		{
+			opts: &ExportOptions{Fields: []string{"results.*"}, CreatedFrom: day.AddDate(0, 0, 2)},
+			exp:  "\n\n",
+		},
+	}
+
+	for i, tc := range cases {
+		c := newTestClient(t, pagedHandler(t, exportTestJobs()))
+
+		out := &bytes.Buffer{}
+		if _, err := ExportCSV(out, c.client.IterateJobs(context.Background(), "", 2), tc.opts); err != nil {
+			t.Fatalf("case %d: failed to export: %v", i, err)
+		}
+
+		if out.String() != tc.exp {
+			t.Errorf("case %d: Expected\n%s\ngot\n%s", i, tc.exp, out)
+		}
+
+		c.server.Close()
+	}
+}
+
+func TestExport_FirstJobWithoutResults(t *testing.T) {
+	jobs := []Job{
+		{UUID: "a", Status: StatusQueued},
+		{UUID: "b", Status: StatusDone, Results: map[string]interface{}{"p": 1.0}},
+	}
+	opts := &ExportOptions{Fields: []string{"results.*"}}
+
+	c := newTestClient(t, pagedHandler(t, jobs))
+	defer c.server.Close()
+
+	out := &bytes.Buffer{}
+	n, err := ExportCSV(out, c.client.IterateJobs(context.Background(), "", 2), opts)
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	// the results are expanded with the first job, which has none, so the header is a single empty line
+	if n != 2 || out.String() != "\n\n\n" {
+		t.Errorf("Expected an empty header and 2 empty rows, got %d %q", n, out)
+	}
+
+	out.Reset()
+	n, err = ExportJSONL(out, c.client.IterateJobs(context.Background(), "", 2), opts)
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	if n != 2 || out.String() != "{}\n{}\n" {
+		t.Errorf("Expected 2 empty objects, got %d %q", n, out)
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	c := newTestClient(t, pagedHandler(t, exportTestJobs()))
+	defer c.server.Close()
+
+	out := &bytes.Buffer{}
+	n, err := ExportJSONL(out, c.client.IterateJobs(context.Background(), "", 2), &ExportOptions{
+		Fields: []string{"uuid", "results.tags.0"},
+	})
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	exp := "{\"results.tags.0\":\"x\",\"uuid\":\"a\"}\n{\"results.tags.0\":null,\"uuid\":\"b\"}\n{\"results.tags.0\":null,\"uuid\":\"c\"}\n"
+	if n != 3 || out.String() != exp {
+		t.Errorf("Expected 3 jobs\n%s\ngot %d\n%s", exp, n, out)
+	}
+
+	out.Reset()
+	if _, err := ExportJSONL(out, c.client.IterateJobs(context.Background(), "", 2), nil); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	job := &Job{}
+	if err := json.NewDecoder(out).Decode(job); err != nil || job.UUID != "a" || job.Results["price"] != 1.5 {
+		t.Errorf("Expected whole job a, got %+v (%v)", job, err)
+	}
+}
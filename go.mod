@@ -1,3 +1,3 @@
 module github.com/scalify/puppet-master-client-go
 
-go 1.14
+go 1.21
@@ -0,0 +1,172 @@
+package puppetmaster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// idempotencyClockSkew is subtracted from the time of the first attempt when looking for jobs it might have created,
+// to cover clock differences between the client and the puppet master.
+const idempotencyClockSkew = time.Minute
+
+// idempotencyPerPage is the page size used to look for jobs created by a previous attempt.
+const idempotencyPerPage = 100
+
+// idempotencyAttemptTTL is how long the first attempt of a key is remembered. Retries after that are treated as a new
+// first attempt, so keys of callers that gave up do not pile up.
+const idempotencyAttemptTTL = time.Hour
+
+// Hash returns a hex encoded SHA-256 hash of the request's code, modules and vars. Requests with equal content
+// produce equal hashes, regardless of nil or empty maps.
+func (r *JobRequest) Hash() string {
+	return contentHash(r.Code, r.Modules, r.Vars)
+}
+
+func contentHash(code string, modules, vars map[string]string) string {
+	if modules == nil {
+		modules = map[string]string{}
+	}
+	if vars == nil {
+		vars = map[string]string{}
+	}
+
+	// maps are marshalled with sorted keys, so the encoding is canonical
+	b, _ := json.Marshal(struct {
+		Code    string            `json:"code"`
+		Modules map[string]string `json:"modules"`
+		Vars    map[string]string `json:"vars"`
+	}{code, modules, vars})
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotencyAttempt is the first attempt to create a job with an idempotency key.
+type idempotencyAttempt struct {
+	first time.Time
+	// uuid is the job created for the key, empty as long as the creation is unconfirmed
+	uuid string
+}
+
+// findPreviousAttempt returns the job created by a previous attempt with the same idempotency key, if any. The first
+// attempt of a key is recorded, so it can be looked up once it is retried, e.g. because waiting for the created job
+// failed. It is forgotten once the request failed for good or idempotencyAttemptTTL passed.
+func (c *Client) findPreviousAttempt(ctx context.Context, jobRequest *JobRequest) (*Job, error) {
+	now := time.Now()
+
+	c.attemptsMu.Lock()
+	for key, attempt := range c.attempts {
+		if now.Sub(attempt.first) > idempotencyAttemptTTL {
+			delete(c.attempts, key)
+		}
+	}
+
+	attempt, ok := c.attempts[jobRequest.IdempotencyKey]
+	if !ok {
+		c.attempts[jobRequest.IdempotencyKey] = &idempotencyAttempt{first: now}
+	}
+	var since time.Time
+	var uuid string
+	if ok {
+		since, uuid = attempt.first, attempt.uuid
+	}
+	c.attemptsMu.Unlock()
+
+	if !ok {
+		return nil, nil
+	}
+
+	if uuid != "" {
+		job, err := c.GetJobContext(ctx, uuid)
+		if err == ErrNotFound {
+			// the job was deleted in the meantime, the request is created anew
+			c.forgetAttempt(jobRequest.IdempotencyKey)
+			return c.findPreviousAttempt(ctx, jobRequest)
+		}
+
+		return job, err
+	}
+
+	job, err := c.findCreatedJob(ctx, jobRequest, since.Add(-idempotencyClockSkew))
+	if err != nil {
+		return nil, err
+	}
+
+	if job != nil {
+		c.rememberCreatedJob(jobRequest.IdempotencyKey, job.UUID)
+	}
+
+	return job, nil
+}
+
+// rememberCreatedJob records the job created for the idempotency key, so retries return it directly.
+func (c *Client) rememberCreatedJob(idempotencyKey, uuid string) {
+	if idempotencyKey == "" {
+		return
+	}
+
+	c.attemptsMu.Lock()
+	defer c.attemptsMu.Unlock()
+
+	if attempt, ok := c.attempts[idempotencyKey]; ok {
+		attempt.uuid = uuid
+	}
+}
+
+func (c *Client) forgetAttempt(idempotencyKey string) {
+	if idempotencyKey == "" {
+		return
+	}
+
+	c.attemptsMu.Lock()
+	defer c.attemptsMu.Unlock()
+
+	delete(c.attempts, idempotencyKey)
+}
+
+// findCreatedJob looks for a job with the same content as the request, created at or after since. Jobs are listed
+// in order of creation, so the pages are walked backwards starting at the last one.
+func (c *Client) findCreatedJob(ctx context.Context, jobRequest *JobRequest, since time.Time) (*Job, error) {
+	first, err := c.GetJobsByStatusContext(ctx, "", 1, idempotencyPerPage)
+	if err != nil {
+		return nil, err
+	}
+
+	lastPage := first.Meta.LastPage
+	if lastPage == 0 {
+		lastPage = 1
+	}
+
+	hash := jobRequest.Hash()
+	for page := lastPage; page >= 1; page-- {
+		jobs := first
+		if page != 1 {
+			if jobs, err = c.GetJobsByStatusContext(ctx, "", page, idempotencyPerPage); err != nil {
+				return nil, err
+			}
+		}
+
+		reachedOlder := false
+		for i := len(jobs.Jobs) - 1; i >= 0; i-- {
+			job := jobs.Jobs[i]
+			if job.CreatedAt.Before(since) {
+				reachedOlder = true
+				continue
+			}
+
+			if contentHash(job.Code, job.Modules, job.Vars) == hash {
+				return &job, nil
+			}
+		}
+
+		if reachedOlder {
+			break
+		}
+	}
+
+	return nil, nil
+}
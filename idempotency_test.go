@@ -0,0 +1,173 @@
+package puppetmaster
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJobRequest_Hash(t *testing.T) {
+	cases := []struct {
+		r1, r2 *JobRequest
+		equal  bool
+	}{
+		{r1: &JobRequest{}, r2: &JobRequest{Vars: map[string]string{}, Modules: map[string]string{}}, equal: true},
+		{r1: &JobRequest{Code: "test"}, r2: &JobRequest{Code: "test", CallbackURL: "http://localhost"}, equal: true},
+		{r1: &JobRequest{Code: "test1"}, r2: &JobRequest{Code: "test2"}, equal: false},
+		{
+			r1:    &JobRequest{Vars: map[string]string{"a": "1", "b": "2"}},
+			r2:    &JobRequest{Vars: map[string]string{"b": "2", "a": "1"}},
+			equal: true,
+		},
+		{
+			r1:    &JobRequest{Vars: map[string]string{"a": "1"}},
+			r2:    &JobRequest{Modules: map[string]string{"a": "1"}},
+			equal: false,
+		},
+	}
+
+	for i, c := range cases {
+		if res := c.r1.Hash() == c.r2.Hash(); res != c.equal {
+			t.Errorf("case %d: Expected equal hashes == %v, got %v", i, c.equal, res)
+		}
+	}
+}
+
+// flakyCreateHandler creates jobs, but fails the first creation after the job was stored.
+type flakyCreateHandler struct {
+	t       *testing.T
+	mu      sync.Mutex
+	jobs    []Job
+	posts   int
+	lastKey string
+}
+
+func (h *flakyCreateHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if req.Method == http.MethodGet {
+		if err := json.NewEncoder(rw).Encode(&JobPagination{Jobs: h.jobs, Meta: PaginationMeta{CurrentPage: 1, LastPage: 1}}); err != nil {
+			h.t.Errorf("failed to encode jobs: %v", err)
+		}
+		return
+	}
+
+	h.posts++
+	h.lastKey = req.Header.Get(idempotencyHeader)
+
+	jobReq := &JobRequest{}
+	if err := json.NewDecoder(req.Body).Decode(jobReq); err != nil {
+		h.t.Errorf("failed to decode request: %v", err)
+	}
+
+	job := Job{UUID: "uuid", Status: StatusCreated, Code: jobReq.Code, Vars: jobReq.Vars, Modules: jobReq.Modules, CreatedAt: time.Now()}
+	h.jobs = append(h.jobs, job)
+
+	if h.posts == 1 {
+		rw.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	rw.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(rw).Encode(&JobResponse{Data: job}); err != nil {
+		h.t.Errorf("failed to encode job: %v", err)
+	}
+}
+
+func TestClient_CreateJobIdempotent(t *testing.T) {
+	h := &flakyCreateHandler{t: t}
+	c := newTestClient(t, h)
+
+	jobReq := &JobRequest{Code: "test", Vars: map[string]string{"page": "test"}, IdempotencyKey: "key"}
+	if _, err := c.client.CreateJob(jobReq); err == nil {
+		t.Fatal("Expected first attempt to fail")
+	}
+
+	job, err := c.client.CreateJob(jobReq)
+	if err != nil {
+		t.Fatalf("failed to retry job creation: %v", err)
+	}
+
+	if job.UUID != "uuid" {
+		t.Errorf("Expected job of first attempt, got %+v", job)
+	}
+
+	if h.posts != 1 {
+		t.Errorf("Expected job to be posted once, got %d", h.posts)
+	}
+
+	if h.lastKey != "key" {
+		t.Errorf("Expected idempotency key header %q, got %q", "key", h.lastKey)
+	}
+}
+
+func TestClient_CreateJobForgetsAttempts(t *testing.T) {
+	cases := []struct {
+		handler http.Handler
+		expKeys int
+	}{
+		{handler: dumbHandler(422, strings.NewReader(`{"errors": {"code": ["invalid"]}}`)), expKeys: 0},
+		{handler: dumbHandler(400, strings.NewReader("bad request")), expKeys: 0},
+		{handler: dumbHandler(502, strings.NewReader("bad gateway")), expKeys: 1},
+	}
+
+	for i, c := range cases {
+		tc := newTestClient(t, c.handler)
+		if _, err := tc.client.CreateJob(&JobRequest{Code: "test", IdempotencyKey: "key"}); err == nil {
+			t.Errorf("case %d: Expected creation to fail", i)
+		}
+		tc.server.Close()
+
+		if n := len(tc.client.attempts); n != c.expKeys {
+			t.Errorf("case %d: Expected %d remembered attempts, got %d", i, c.expKeys, n)
+		}
+	}
+}
+
+func TestClient_CreateJobAttemptTTL(t *testing.T) {
+	h := &flakyCreateHandler{t: t}
+	c := newTestClient(t, h)
+
+	c.client.attempts["stale"] = &idempotencyAttempt{first: time.Now().Add(-idempotencyAttemptTTL - time.Minute)}
+	c.client.attempts["key"] = &idempotencyAttempt{first: time.Now().Add(-idempotencyAttemptTTL - time.Minute), uuid: "gone"}
+
+	// the expired attempt is not looked up, the job is posted as a first attempt
+	jobReq := &JobRequest{Code: "test", IdempotencyKey: "key"}
+	if _, err := c.client.CreateJob(jobReq); err == nil {
+		t.Fatal("Expected first attempt to fail")
+	}
+
+	if h.posts != 1 {
+		t.Errorf("Expected job to be posted, got %d posts", h.posts)
+	}
+
+	if _, ok := c.client.attempts["stale"]; ok {
+		t.Error("Expected expired attempt to be removed")
+	}
+
+	if attempt := c.client.attempts["key"]; time.Since(attempt.first) > time.Minute || attempt.uuid != "" {
+		t.Errorf("Expected attempt to be recorded anew, got %+v", attempt)
+	}
+}
+
+func TestClient_CreateJobWithoutIdempotencyKey(t *testing.T) {
+	h := &flakyCreateHandler{t: t}
+	c := newTestClient(t, h)
+
+	jobReq := &JobRequest{Code: "test"}
+	if _, err := c.client.CreateJob(jobReq); err == nil {
+		t.Fatal("Expected first attempt to fail")
+	}
+
+	if _, err := c.client.CreateJob(jobReq); err != nil {
+		t.Fatalf("failed to retry job creation: %v", err)
+	}
+
+	if h.posts != 2 {
+		t.Errorf("Expected job to be posted twice, got %d", h.posts)
+	}
+}
@@ -0,0 +1,85 @@
+package puppetmaster
+
+import (
+	"context"
+	"fmt"
+)
+
+// JobIterator walks through all jobs of a paginated listing, fetching one page at a time. Use it like a
+// bufio.Scanner:
+//
+//	jobs := client.IterateJobs(ctx, StatusDone, 100)
+//	for jobs.Next() {
+//		job := jobs.Job()
+//	}
+//	if err := jobs.Err(); err != nil {
+//		...
+//	}
+type JobIterator struct {
+	client  *Client
+	ctx     context.Context
+	status  string
+	perPage uint
+
+	page     uint
+	lastPage uint
+	jobs     []Job
+	index    int
+	err      error
+}
+
+// IterateJobs returns a JobIterator over all jobs with the given status, or all jobs if status is empty, requesting
+// perPage jobs at once.
+func (c *Client) IterateJobs(ctx context.Context, status string, perPage uint) *JobIterator {
+	if perPage == 0 {
+		perPage = 100
+	}
+
+	return &JobIterator{
+		client:  c,
+		ctx:     ctx,
+		status:  status,
+		perPage: perPage,
+		index:   -1,
+	}
+}
+
+// Next advances to the next job, fetching the next page if needed. It returns false when all jobs were visited or
+// fetching a page failed, see Err().
+func (it *JobIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.index++
+	for it.index >= len(it.jobs) {
+		if it.page > 0 && it.page >= it.lastPage {
+			return false
+		}
+
+		it.page++
+		jobs, err := it.client.GetJobsByStatusContext(it.ctx, it.status, it.page, it.perPage)
+		if err != nil {
+			it.err = fmt.Errorf("failed to list jobs on page %d: %v", it.page, err)
+			return false
+		}
+
+		if len(jobs.Jobs) == 0 {
+			return false
+		}
+
+		it.jobs, it.index, it.lastPage = jobs.Jobs, 0, jobs.Meta.LastPage
+	}
+
+	return true
+}
+
+// Job returns the current job.
+func (it *JobIterator) Job() *Job {
+	return &it.jobs[it.index]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *JobIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,287 @@
+package puppetmaster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Journal records jobs submitted by ExecuteSync until they are done, so they can be picked up by Client.Resume()
+// after the process died while waiting.
+type Journal interface {
+	// Record stores a submitted job.
+	Record(entry JournalEntry) error
+	// Complete marks the job with the given UUID as done.
+	Complete(uuid string) error
+	// Pending returns all recorded jobs that were not completed yet, in the order they were recorded.
+	Pending() ([]JournalEntry, error)
+}
+
+// JournalEntry describes a submitted job. The request is kept, so a job that vanished from the puppet master can be
+// submitted again.
+type JournalEntry struct {
+	UUID        string      `json:"uuid"`
+	RequestHash string      `json:"request_hash"`
+	Request     *JobRequest `json:"request,omitempty"`
+	// IdempotencyKey is the key of the request, which is not part of its JSON encoding.
+	IdempotencyKey string    `json:"idempotency_key,omitempty"`
+	SubmittedAt    time.Time `json:"submitted_at"`
+}
+
+// ResumeHandler receives the result of a job picked up by Client.Resume(). It is called concurrently for all jobs.
+type ResumeHandler func(entry JournalEntry, job *Job, err error)
+
+// SetJournal makes ExecuteSync record every submitted job in the journal until it is done.
+func (c *Client) SetJournal(journal Journal) {
+	c.journal = journal
+}
+
+// Resume waits for all pending jobs of the journal set by Client.SetJournal() and passes each result to handler.
+// Jobs that are done or vanished from the puppet master are completed in the journal, jobs whose wait failed stay
+// pending. Resume returns once every job was handled or ctx is done.
+func (c *Client) Resume(ctx context.Context, handler ResumeHandler) error {
+	if c.journal == nil {
+		return ErrNoJournal
+	}
+
+	entries, err := c.journal.Pending()
+	if err != nil {
+		return fmt.Errorf("failed to read pending jobs from journal: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, entry := range entries {
+		wg.Add(1)
+		go func(entry JournalEntry) {
+			defer wg.Done()
+
+			job, err := c.waitForJob(ctx, entry.UUID, newJobTracker(nil))
+			if err == nil || err == ErrNotFound {
+				c.completeInJournal(entry.UUID)
+			}
+
+			handler(entry, job, err)
+		}(entry)
+	}
+
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// recordInJournal and completeInJournal keep the journal up to date on a best-effort basis, a failing journal does
+// not fail the job.
+func (c *Client) recordInJournal(jobRequest *JobRequest, job *Job) {
+	if c.journal == nil {
+		return
+	}
+
+	entry := JournalEntry{
+		UUID:        job.UUID,
+		RequestHash: jobRequest.Hash(),
+		Request: &JobRequest{
+			Code:        jobRequest.Code,
+			Vars:        copyStringMap(jobRequest.Vars),
+			Modules:     copyStringMap(jobRequest.Modules),
+			CallbackURL: jobRequest.CallbackURL,
+		},
+		IdempotencyKey: jobRequest.IdempotencyKey,
+		SubmittedAt:    time.Now(),
+	}
+	if err := c.journal.Record(entry); err != nil && c.debug {
+		log.Printf("failed to record job %v in journal: %v", job.UUID, err)
+	}
+}
+
+func (c *Client) completeInJournal(uuid string) {
+	if c.journal == nil {
+		return
+	}
+
+	if err := c.journal.Complete(uuid); err != nil && c.debug {
+		log.Printf("failed to complete job %v in journal: %v", uuid, err)
+	}
+}
+
+// journalOp is a single line of a FileJournal.
+type journalOp struct {
+	Op    string        `json:"op"`
+	Entry *JournalEntry `json:"entry,omitempty"`
+	UUID  string        `json:"uuid,omitempty"`
+}
+
+const (
+	journalOpRecord   = "record"
+	journalOpComplete = "complete"
+)
+
+// FileJournal is a Journal appending every change as JSON line to a file.
+type FileJournal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileJournal opens or creates the journal file at the given path. Completed jobs are dropped from the file on
+// open, so it does not grow forever.
+func NewFileJournal(path string) (*FileJournal, error) {
+	j := &FileJournal{path: path}
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %v", err)
+	}
+	j.file = f
+
+	return j, nil
+}
+
+// Record implements Journal.
+func (j *FileJournal) Record(entry JournalEntry) error {
+	return j.append(&journalOp{Op: journalOpRecord, Entry: &entry})
+}
+
+// Complete implements Journal.
+func (j *FileJournal) Complete(uuid string) error {
+	return j.append(&journalOp{Op: journalOpComplete, UUID: uuid})
+}
+
+// Pending implements Journal.
+func (j *FileJournal) Pending() ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return readJournal(j.path)
+}
+
+// Close closes the journal file.
+func (j *FileJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.file.Close()
+}
+
+func (j *FileJournal) append(op *journalOp) error {
+	b, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	return j.file.Sync()
+}
+
+// compact rewrites the journal with only the pending entries.
+func (j *FileJournal) compact() error {
+	entries, err := readJournal(j.path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), ".journal-*")
+	if err != nil {
+		return fmt.Errorf("failed to compact journal: %v", err)
+	}
+
+	enc := json.NewEncoder(tmp)
+	for i := range entries {
+		if err := enc.Encode(&journalOp{Op: journalOpRecord, Entry: &entries[i]}); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to compact journal: %v", err)
+		}
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to compact journal: %v", err)
+	}
+
+	return os.Rename(tmp.Name(), j.path)
+}
+
+func readJournal(path string) ([]JournalEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %v", err)
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	index := map[string]int{}
+
+	// lines are read without length limit, requests may carry large scripts
+	reader := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		b, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read journal: %v", err)
+		}
+		last := err == io.EOF
+
+		if len(bytes.TrimSpace(b)) == 0 {
+			if last {
+				break
+			}
+			continue
+		}
+
+		op := &journalOp{}
+		if err := json.Unmarshal(b, op); err != nil {
+			// a crash may leave a partially written last line behind, anything else is corruption
+			if last {
+				break
+			}
+			return nil, fmt.Errorf("corrupt journal line %d: %v", line, err)
+		}
+
+		switch op.Op {
+		case journalOpRecord:
+			if op.Entry != nil {
+				index[op.Entry.UUID] = len(entries)
+				entries = append(entries, *op.Entry)
+			}
+		case journalOpComplete:
+			if i, ok := index[op.UUID]; ok {
+				entries[i].UUID = ""
+				delete(index, op.UUID)
+			}
+		default:
+			return nil, fmt.Errorf("unknown journal operation %q in line %d", op.Op, line)
+		}
+
+		if last {
+			break
+		}
+	}
+
+	var pending []JournalEntry
+	for _, e := range entries {
+		if e.UUID != "" {
+			pending = append(pending, e)
+		}
+	}
+
+	return pending, nil
+}
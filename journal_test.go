@@ -0,0 +1,154 @@
+package puppetmaster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestJournal(t *testing.T, path string) *FileJournal {
+	j, err := NewFileJournal(path)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := j.Close(); err != nil {
+			t.Errorf("failed to close journal: %v", err)
+		}
+	})
+
+	return j
+}
+
+func TestFileJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j := newTestJournal(t, path)
+
+	for _, uuid := range []string{"a", "b", "c"} {
+		if err := j.Record(JournalEntry{UUID: uuid, SubmittedAt: time.Now()}); err != nil {
+			t.Fatalf("failed to record job: %v", err)
+		}
+	}
+
+	if err := j.Complete("b"); err != nil {
+		t.Fatalf("failed to complete job: %v", err)
+	}
+
+	// simulate a crash in the middle of writing a line
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("failed to open journal file: %v", err)
+	}
+	if _, err := f.WriteString(`{"op":"complete","uu`); err != nil {
+		t.Fatalf("failed to write journal file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("failed to close journal file: %v", err)
+	}
+
+	pending, err := newTestJournal(t, path).Pending()
+	if err != nil {
+		t.Fatalf("failed to read pending jobs: %v", err)
+	}
+
+	if len(pending) != 2 || pending[0].UUID != "a" || pending[1].UUID != "c" {
+		t.Errorf("Expected jobs a and c to be pending, got %+v", pending)
+	}
+}
+
+func TestFileJournal_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	content := `{"op":"record","entry":{"uuid":"a"}}` + "\n" + `{"op":"compl` + "\n" + `{"op":"record","entry":{"uuid":"b"}}` + "\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write journal file: %v", err)
+	}
+
+	if _, err := NewFileJournal(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected corrupt line 2 to be reported, got %v", err)
+	}
+}
+
+func TestClient_ExecuteSyncJournal(t *testing.T) {
+	c := newTestClient(t, sequenceHandler(t, Job{UUID: "uuid", Status: StatusCreated}, Job{UUID: "uuid", Status: StatusDone}))
+	c.client.SetSyncSleepMs(1)
+
+	j := newTestJournal(t, filepath.Join(t.TempDir(), "journal.jsonl"))
+	c.client.SetJournal(j)
+
+	if _, err := c.client.ExecuteSync(&JobRequest{Code: "test"}); err != nil {
+		t.Fatalf("failed to execute job: %v", err)
+	}
+
+	pending, err := j.Pending()
+	if err != nil {
+		t.Fatalf("failed to read pending jobs: %v", err)
+	}
+
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending jobs, got %+v", pending)
+	}
+
+	// a job that is still running when the process dies stays in the journal with its request
+	c = newTestClient(t, sequenceHandler(t, Job{UUID: "running", Status: StatusQueued}))
+	c.client.SetSyncSleepMs(1)
+	c.client.SetJournal(j)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	jobReq := &JobRequest{Code: "test", Vars: map[string]string{"page": "1"}, IdempotencyKey: "key"}
+	if _, err := c.client.ExecuteSyncWithOptions(ctx, jobReq, nil); err == nil {
+		t.Fatal("Expected execution to time out")
+	}
+
+	if pending, err = j.Pending(); err != nil {
+		t.Fatalf("failed to read pending jobs: %v", err)
+	}
+
+	if len(pending) != 1 || pending[0].Request == nil || pending[0].Request.Vars["page"] != "1" || pending[0].IdempotencyKey != "key" {
+		t.Errorf("Expected pending job with its request, got %+v", pending)
+	}
+}
+
+func TestClient_Resume(t *testing.T) {
+	c := newTestClient(t, sequenceHandler(t, Job{UUID: "uuid", Status: StatusQueued}, Job{UUID: "uuid", Status: StatusDone}))
+	c.client.SetSyncSleepMs(1)
+
+	if err := c.client.Resume(context.Background(), nil); err != ErrNoJournal {
+		t.Fatalf("Expected ErrNoJournal, got %v", err)
+	}
+
+	j := newTestJournal(t, filepath.Join(t.TempDir(), "journal.jsonl"))
+	if err := j.Record(JournalEntry{UUID: "uuid"}); err != nil {
+		t.Fatalf("failed to record job: %v", err)
+	}
+	c.client.SetJournal(j)
+
+	var mu sync.Mutex
+	var resumed []*Job
+	err := c.client.Resume(context.Background(), func(entry JournalEntry, job *Job, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			t.Errorf("failed to resume job %v: %v", entry.UUID, err)
+		}
+		resumed = append(resumed, job)
+	})
+	if err != nil {
+		t.Fatalf("failed to resume: %v", err)
+	}
+
+	if len(resumed) != 1 || resumed[0].Status != StatusDone {
+		t.Errorf("Expected the done job to be resumed, got %+v", resumed)
+	}
+
+	if pending, _ := j.Pending(); len(pending) != 0 {
+		t.Errorf("Expected no pending jobs, got %+v", pending)
+	}
+}
@@ -0,0 +1,165 @@
+package puppetmaster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogLevel is the severity of a Log line as reported by the executor.
+type LogLevel string
+
+// possible log levels, ordered by severity
+const (
+	LevelDebug LogLevel = "DEBUG"
+	LevelInfo  LogLevel = "INFO"
+	LevelWarn  LogLevel = "WARN"
+	LevelError LogLevel = "ERROR"
+)
+
+// severity maps a level to a comparable number. Unknown levels rank below LevelDebug.
+func (l LogLevel) severity() int {
+	switch strings.ToUpper(string(l)) {
+	case "DEBUG":
+		return 1
+	case "INFO":
+		return 2
+	case "WARN", "WARNING":
+		return 3
+	case "ERROR":
+		return 4
+	default:
+		return 0
+	}
+}
+
+// Compare returns -1 if l is less severe than l2, 1 if it is more severe and 0 if both are equally severe.
+func (l LogLevel) Compare(l2 LogLevel) int {
+	s1, s2 := l.severity(), l2.severity()
+	switch {
+	case s1 < s2:
+		return -1
+	case s1 > s2:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// slogLevel converts the level into the matching slog.Level.
+func (l LogLevel) slogLevel() slog.Level {
+	switch l.severity() {
+	case 1:
+		return slog.LevelDebug
+	case 3:
+		return slog.LevelWarn
+	case 4:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// LogsAtLeast returns all logs with a level of at least the given level.
+func (j *Job) LogsAtLeast(level LogLevel) []Log {
+	return j.filterLogs(func(l Log) bool {
+		return l.Level.Compare(level) >= 0
+	})
+}
+
+// LogsBetween returns all logs written between from and to, both inclusive.
+func (j *Job) LogsBetween(from, to time.Time) []Log {
+	return j.filterLogs(func(l Log) bool {
+		return !l.Time.Before(from) && !l.Time.After(to)
+	})
+}
+
+// LogsMatching returns all logs whose message matches the given expression.
+func (j *Job) LogsMatching(re *regexp.Regexp) []Log {
+	return j.filterLogs(func(l Log) bool {
+		return re.MatchString(l.Message)
+	})
+}
+
+// HasErrors returns true when the job failed or logged at least one line with LevelError.
+func (j *Job) HasErrors() bool {
+	return j.Error != "" || len(j.LogsAtLeast(LevelError)) > 0
+}
+
+func (j *Job) filterLogs(keep func(Log) bool) []Log {
+	var logs []Log
+	for _, l := range j.Logs {
+		if keep(l) {
+			logs = append(logs, l)
+		}
+	}
+
+	return logs
+}
+
+// WriteLogsText writes the logs to w, one line per log in the form "<time> <level> <message>".
+func WriteLogsText(w io.Writer, logs []Log) error {
+	for _, l := range logs {
+		if _, err := fmt.Fprintf(w, "%s %s %s\n", l.Time.Format(time.RFC3339Nano), l.Level, l.Message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteLogsJSON writes the logs to w as JSON lines.
+func WriteLogsJSON(w io.Writer, logs []Log) error {
+	enc := json.NewEncoder(w)
+	for _, l := range logs {
+		if err := enc.Encode(l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteLogsLogfmt writes the logs to w in logfmt format.
+func WriteLogsLogfmt(w io.Writer, logs []Log) error {
+	for _, l := range logs {
+		_, err := fmt.Fprintf(w, "time=%s level=%s msg=%s\n",
+			l.Time.Format(time.RFC3339Nano), logfmtValue(string(l.Level)), logfmtValue(l.Message))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func logfmtValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " =\"\t\r\n") {
+		return strconv.Quote(v)
+	}
+
+	return v
+}
+
+// ReplayLogs hands the logs to the given logger, keeping their original timestamps.
+func ReplayLogs(ctx context.Context, logger *slog.Logger, logs []Log) error {
+	h := logger.Handler()
+	for _, l := range logs {
+		level := l.Level.slogLevel()
+		if !h.Enabled(ctx, level) {
+			continue
+		}
+
+		if err := h.Handle(ctx, slog.NewRecord(l.Time, level, l.Message, 0)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
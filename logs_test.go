@@ -0,0 +1,140 @@
+package puppetmaster
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLogJob() *Job {
+	start := time.Date(2018, 8, 13, 11, 55, 17, 0, time.UTC)
+	return &Job{
+		Logs: []Log{
+			{Time: start, Level: LevelDebug, Message: "opening page"},
+			{Time: start.Add(1 * time.Second), Level: LevelInfo, Message: "ip 127.0.0.1"},
+			{Time: start.Add(2 * time.Second), Level: "warning", Message: "slow response"},
+			{Time: start.Add(3 * time.Second), Level: LevelError, Message: "selector not found"},
+		},
+	}
+}
+
+func TestLogLevel_Compare(t *testing.T) {
+	cases := []struct {
+		l1, l2 LogLevel
+		exp    int
+	}{
+		{l1: LevelDebug, l2: LevelInfo, exp: -1},
+		{l1: LevelError, l2: LevelWarn, exp: 1},
+		{l1: "warning", l2: LevelWarn, exp: 0},
+		{l1: "info", l2: LevelInfo, exp: 0},
+		{l1: "unknown", l2: LevelDebug, exp: -1},
+	}
+
+	for i, c := range cases {
+		res := c.l1.Compare(c.l2)
+		if res != c.exp {
+			t.Errorf("case %d: Expected %q.Compare(%q) == %d, got %d", i, c.l1, c.l2, c.exp, res)
+		}
+	}
+}
+
+func TestJob_LogsAtLeast(t *testing.T) {
+	logs := testLogJob().LogsAtLeast(LevelWarn)
+	if len(logs) != 2 {
+		t.Fatalf("Expected 2 logs, got %d", len(logs))
+	}
+
+	if logs[0].Message != "slow response" || logs[1].Message != "selector not found" {
+		t.Errorf("Unexpected logs %+v", logs)
+	}
+}
+
+func TestJob_LogsBetween(t *testing.T) {
+	job := testLogJob()
+	logs := job.LogsBetween(job.Logs[1].Time, job.Logs[2].Time)
+	if len(logs) != 2 {
+		t.Fatalf("Expected 2 logs, got %d", len(logs))
+	}
+}
+
+func TestJob_LogsMatching(t *testing.T) {
+	logs := testLogJob().LogsMatching(regexp.MustCompile(`^ip \d+`))
+	if len(logs) != 1 || logs[0].Level != LevelInfo {
+		t.Fatalf("Expected only the info log, got %+v", logs)
+	}
+}
+
+func TestJob_HasErrors(t *testing.T) {
+	cases := []struct {
+		job *Job
+		exp bool
+	}{
+		{job: &Job{}, exp: false},
+		{job: &Job{Error: "failed"}, exp: true},
+		{job: &Job{Logs: []Log{{Level: LevelInfo}}}, exp: false},
+		{job: testLogJob(), exp: true},
+	}
+
+	for i, c := range cases {
+		if res := c.job.HasErrors(); res != c.exp {
+			t.Errorf("case %d: Expected HasErrors() == %v, got %v", i, c.exp, res)
+		}
+	}
+}
+
+func TestWriteLogs(t *testing.T) {
+	logs := testLogJob().Logs[:2]
+	cases := []struct {
+		write func(*bytes.Buffer) error
+		exp   string
+	}{
+		{
+			write: func(b *bytes.Buffer) error { return WriteLogsText(b, logs) },
+			exp:   "2018-08-13T11:55:17Z DEBUG opening page\n2018-08-13T11:55:18Z INFO ip 127.0.0.1\n",
+		},
+		{
+			write: func(b *bytes.Buffer) error { return WriteLogsJSON(b, logs) },
+			exp: `{"time":"2018-08-13T11:55:17Z","level":"DEBUG","message":"opening page"}` + "\n" +
+				`{"time":"2018-08-13T11:55:18Z","level":"INFO","message":"ip 127.0.0.1"}` + "\n",
+		},
+		{
+			write: func(b *bytes.Buffer) error { return WriteLogsLogfmt(b, logs) },
+			exp: `time=2018-08-13T11:55:17Z level=DEBUG msg="opening page"` + "\n" +
+				`time=2018-08-13T11:55:18Z level=INFO msg="ip 127.0.0.1"` + "\n",
+		},
+	}
+
+	for i, c := range cases {
+		b := &bytes.Buffer{}
+		if err := c.write(b); err != nil {
+			t.Fatalf("case %d: failed to write logs: %v", i, err)
+		}
+
+		if b.String() != c.exp {
+			t.Errorf("case %d: Expected output\n%s\ngot\n%s", i, c.exp, b.String())
+		}
+	}
+}
+
+func TestReplayLogs(t *testing.T) {
+	b := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(b, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	if err := ReplayLogs(context.Background(), logger, testLogJob().Logs); err != nil {
+		t.Fatalf("failed to replay logs: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 replayed lines, got %d: %v", len(lines), lines)
+	}
+
+	exp := `time=2018-08-13T11:55:18.000Z level=INFO msg="ip 127.0.0.1"`
+	if lines[0] != exp {
+		t.Errorf("Expected first line %q, got %q", exp, lines[0])
+	}
+}
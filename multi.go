@@ -0,0 +1,194 @@
+package puppetmaster
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TeamConfig configures the Client of a single team within a MultiClient.
+type TeamConfig struct {
+	// Tokens supplies the API token of the team.
+	Tokens TokenSource
+	// RequestsPerSecond and Burst limit the requests sent for the team, see Client.SetRateLimit().
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// TeamJob is a job tagged with the team it belongs to.
+type TeamJob struct {
+	Team string
+	Job  Job
+}
+
+// TeamErrors holds the errors of the teams a MultiClient failed to fetch jobs for.
+type TeamErrors map[string]error
+
+// Error implements error.
+func (e TeamErrors) Error() string {
+	teams := make([]string, 0, len(e))
+	for team := range e {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+
+	errs := make([]string, 0, len(e))
+	for _, team := range teams {
+		errs = append(errs, fmt.Sprintf("%s: %v", team, e[team]))
+	}
+
+	return fmt.Sprintf("failed to fetch jobs of %d teams: %v", len(e), strings.Join(errs, ", "))
+}
+
+// MultiClient manages one Client per team, so jobs can be sent on behalf of many teams.
+type MultiClient struct {
+	baseURL string
+
+	mu      sync.RWMutex
+	clients map[string]*Client
+	debug   bool
+}
+
+// NewMultiClient returns a new MultiClient. The baseURL is the API root without the team, e.g.
+// https://puppet-master.io/api/v1.
+func NewMultiClient(baseURL string) (*MultiClient, error) {
+	u, err := parseBaseURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultiClient{
+		baseURL: u.String(),
+		clients: map[string]*Client{},
+	}, nil
+}
+
+// EnableDebugLogs enables debug logging for all current and future team clients.
+func (m *MultiClient) EnableDebugLogs() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.debug = true
+	for _, c := range m.clients {
+		c.EnableDebugLogs()
+	}
+}
+
+// AddTeam creates the Client for the given team, replacing any previous one.
+func (m *MultiClient) AddTeam(team string, config TeamConfig) (*Client, error) {
+	if strings.TrimSpace(team) == "" {
+		return nil, ErrEmptyTeam
+	}
+
+	// the team becomes a path segment of the API URL and may not reach out of it
+	if strings.ContainsAny(team, `/\`) || team == "." || team == ".." {
+		return nil, fmt.Errorf("team %q: %w", team, ErrInvalidTeam)
+	}
+
+	teamURL, err := url.Parse(m.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	teamURL.RawPath = path.Join(teamURL.EscapedPath(), "teams", url.PathEscape(team))
+	teamURL.Path = path.Join(teamURL.Path, "teams", team)
+
+	c, err := NewClientWithTokenSource(teamURL.String(), config.Tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	c.SetRateLimit(config.RequestsPerSecond, config.Burst)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.debug {
+		c.EnableDebugLogs()
+	}
+	m.clients[team] = c
+
+	return c, nil
+}
+
+// RemoveTeam drops the Client of the given team.
+func (m *MultiClient) RemoveTeam(team string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.clients, team)
+}
+
+// Team returns the Client of the given team.
+func (m *MultiClient) Team(team string) (*Client, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.clients[team]
+	if !ok {
+		return nil, fmt.Errorf("team %q: %w", team, ErrUnknownTeam)
+	}
+
+	return c, nil
+}
+
+// Teams returns the names of all teams, sorted alphabetically.
+func (m *MultiClient) Teams() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	teams := make([]string, 0, len(m.clients))
+	for team := range m.clients {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+
+	return teams
+}
+
+// GetJobsByStatus fetches the given page of jobs with the given status for all teams concurrently. Jobs are grouped
+// by team in the order of Teams(). If fetching fails for some teams, the jobs of the others are returned along with
+// TeamErrors.
+func (m *MultiClient) GetJobsByStatus(ctx context.Context, status string, page, perPage uint) ([]TeamJob, error) {
+	teams := m.Teams()
+	pages := make([]*JobPagination, len(teams))
+	errs := make([]error, len(teams))
+
+	var wg sync.WaitGroup
+	for i, team := range teams {
+		c, err := m.Team(team)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, c *Client) {
+			defer wg.Done()
+			pages[i], errs[i] = c.GetJobsByStatusContext(ctx, status, page, perPage)
+		}(i, c)
+	}
+	wg.Wait()
+
+	var jobs []TeamJob
+	teamErrs := TeamErrors{}
+	for i, team := range teams {
+		if errs[i] != nil {
+			teamErrs[team] = errs[i]
+			continue
+		}
+
+		for _, job := range pages[i].Jobs {
+			jobs = append(jobs, TeamJob{Team: team, Job: job})
+		}
+	}
+
+	if len(teamErrs) > 0 {
+		return jobs, teamErrs
+	}
+
+	return jobs, nil
+}
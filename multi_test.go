@@ -0,0 +1,103 @@
+package puppetmaster
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMultiClient(t *testing.T) {
+	jobs := readTestData(t, "get-jobs-response.json")
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get(authHeader) != "Bearer token-"+strings.Split(req.URL.Path, "/")[4] {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if strings.HasPrefix(req.URL.Path, "/api/v1/teams/broken/") {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = rw.Write(jobs)
+	}))
+	defer server.Close()
+
+	m, err := NewMultiClient(server.URL + "/api/v1")
+	if err != nil {
+		t.Fatalf("failed to construct multi client: %v", err)
+	}
+
+	for _, team := range []string{"b-team", "a-team", "broken"} {
+		if _, err := m.AddTeam(team, TeamConfig{Tokens: NewStaticTokenSource("token-" + team)}); err != nil {
+			t.Fatalf("failed to add team %v: %v", team, err)
+		}
+	}
+
+	if _, err := m.AddTeam(" ", TeamConfig{Tokens: NewStaticTokenSource("token")}); err != ErrEmptyTeam {
+		t.Errorf("Expected ErrEmptyTeam, got %v", err)
+	}
+
+	for _, team := range []string{"..", ".", "a/../b", `a\b`} {
+		if _, err := m.AddTeam(team, TeamConfig{Tokens: NewStaticTokenSource("token")}); !errors.Is(err, ErrInvalidTeam) {
+			t.Errorf("Expected ErrInvalidTeam for %q, got %v", team, err)
+		}
+	}
+
+	special, err := m.AddTeam("q?#%team", TeamConfig{Tokens: NewStaticTokenSource("token")})
+	if err != nil {
+		t.Fatalf("failed to add team with special characters: %v", err)
+	}
+
+	if exp := server.URL + "/api/v1/teams/q%3F%23%25team/jobs"; special.buildURL("jobs", nil) != exp {
+		t.Errorf("Expected escaped team url %v, got %v", exp, special.buildURL("jobs", nil))
+	}
+	m.RemoveTeam("q?#%team")
+
+	if _, err := m.Team("c-team"); !errors.Is(err, ErrUnknownTeam) {
+		t.Errorf("Expected ErrUnknownTeam, got %v", err)
+	}
+
+	c, err := m.Team("a-team")
+	if err != nil {
+		t.Fatalf("failed to get team client: %v", err)
+	}
+
+	if exp := server.URL + "/api/v1/teams/a-team/jobs"; c.buildURL("jobs", nil) != exp {
+		t.Errorf("Expected team url %v, got %v", exp, c.buildURL("jobs", nil))
+	}
+
+	res, err := m.GetJobsByStatus(context.Background(), "", 1, 15)
+	teamErrs, ok := err.(TeamErrors)
+	if !ok || len(teamErrs) != 1 || teamErrs["broken"] == nil {
+		t.Fatalf("Expected error of broken team only, got %v", err)
+	}
+
+	if len(res) != 20 || res[0].Team != "a-team" || res[19].Team != "b-team" {
+		t.Errorf("Expected 10 jobs of each working team in team order, got %d", len(res))
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	r := newRateLimiter(10, 2)
+	r.last = now
+
+	for i := 0; i < 2; i++ {
+		if d := r.reserve(now); d != 0 {
+			t.Fatalf("Expected burst request %d to pass, got delay %v", i, d)
+		}
+	}
+
+	if d := r.reserve(now); d != 100*time.Millisecond {
+		t.Errorf("Expected delay of 100ms, got %v", d)
+	}
+
+	if d := r.reserve(now.Add(100 * time.Millisecond)); d != 0 {
+		t.Errorf("Expected refilled token, got delay %v", d)
+	}
+}
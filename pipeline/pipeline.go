@@ -0,0 +1,361 @@
+// Package pipeline executes puppet-master jobs that depend on each other as a directed acyclic graph. Every node
+// builds its JobRequest from the finished jobs of its upstream nodes, e.g. to pass a session cookie a login job put
+// into its results on to the jobs using it.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+var (
+	// ErrEmptyName is thrown when a Node without a name is added.
+	ErrEmptyName = errors.New("node name may not be empty")
+
+	// ErrUpstreamFailed is the error of nodes skipped because an upstream node failed.
+	ErrUpstreamFailed = errors.New("skipped, upstream node failed")
+
+	// ErrPipelineStopped is the error of nodes skipped because the pipeline stopped after a failure.
+	ErrPipelineStopped = errors.New("skipped, pipeline stopped after a failure")
+)
+
+// FailurePolicy decides how the pipeline continues after a node failed.
+type FailurePolicy int
+
+// possible failure policies
+const (
+	// StopOnFailure cancels all running nodes and skips all nodes not started yet.
+	StopOnFailure FailurePolicy = iota
+	// ContinueOnFailure only skips the nodes depending on the failed one, independent branches keep running.
+	ContinueOnFailure
+)
+
+// Node is a single job of the pipeline.
+type Node struct {
+	// Name identifies the node, it is used in DependsOn and the Report.
+	Name string
+	// DependsOn lists the names of the nodes that have to succeed before this node starts.
+	DependsOn []string
+	// Build creates the job from the finished jobs of the upstream nodes, keyed by their name.
+	Build func(upstream map[string]*puppetmaster.Job) (*puppetmaster.JobRequest, error)
+}
+
+// Status is the outcome of a node.
+type Status string
+
+// possible node states
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+)
+
+// NodeResult is the outcome of a single node.
+type NodeResult struct {
+	Name     string
+	Status   Status
+	Job      *puppetmaster.Job
+	Err      error
+	Started  time.Time
+	Finished time.Time
+}
+
+// Report is the outcome of a pipeline run.
+type Report struct {
+	Started  time.Time
+	Finished time.Time
+	// Nodes holds the result of every node in the order they were added.
+	Nodes []*NodeResult
+}
+
+// Succeeded returns true if all nodes succeeded.
+func (r *Report) Succeeded() bool {
+	for _, n := range r.Nodes {
+		if n.Status != StatusSucceeded {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Node returns the result of the node with the given name, or nil if there is none.
+func (r *Report) Node(name string) *NodeResult {
+	for _, n := range r.Nodes {
+		if n.Name == name {
+			return n
+		}
+	}
+
+	return nil
+}
+
+// Pipeline is a directed acyclic graph of jobs.
+type Pipeline struct {
+	executor    puppetmaster.Executor
+	policy      FailurePolicy
+	concurrency int
+
+	nodes []Node
+	index map[string]int
+}
+
+// New returns a new, empty Pipeline executing its jobs through the given executor.
+func New(executor puppetmaster.Executor) *Pipeline {
+	return &Pipeline{
+		executor: executor,
+		index:    map[string]int{},
+	}
+}
+
+// SetFailurePolicy sets how the pipeline continues after a node failed, StopOnFailure by default.
+func (p *Pipeline) SetFailurePolicy(policy FailurePolicy) {
+	p.policy = policy
+}
+
+// SetConcurrency limits how many jobs run at the same time. 0, the default, runs all independent nodes at once.
+func (p *Pipeline) SetConcurrency(concurrency int) {
+	p.concurrency = concurrency
+}
+
+// Add adds a node. Its dependencies do not have to be added yet, they are checked by Validate() and Run().
+func (p *Pipeline) Add(node Node) error {
+	if strings.TrimSpace(node.Name) == "" {
+		return ErrEmptyName
+	}
+
+	if node.Build == nil {
+		return fmt.Errorf("node %q: build may not be nil", node.Name)
+	}
+
+	if _, ok := p.index[node.Name]; ok {
+		return fmt.Errorf("node %q was already added", node.Name)
+	}
+
+	p.index[node.Name] = len(p.nodes)
+	p.nodes = append(p.nodes, node)
+
+	return nil
+}
+
+// Validate checks that all dependencies exist and the graph contains no cycles.
+func (p *Pipeline) Validate() error {
+	for _, n := range p.nodes {
+		for _, dep := range n.DependsOn {
+			if _, ok := p.index[dep]; !ok {
+				return fmt.Errorf("node %q depends on unknown node %q", n.Name, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(p.nodes))
+
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		path = append(path, p.nodes[i].Name)
+
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %v", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+
+		state[i] = visiting
+		for _, dep := range p.nodes[i].DependsOn {
+			if err := visit(p.index[dep], path); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+
+		return nil
+	}
+
+	for i := range p.nodes {
+		if err := visit(i, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run executes all nodes, starting every node as soon as all of its upstream nodes succeeded. It only returns an
+// error if the pipeline is invalid, the outcome of the nodes is part of the Report.
+func (p *Pipeline) Run(ctx context.Context) (*Report, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	r := &run{
+		pipeline: p,
+		results:  make([]*NodeResult, len(p.nodes)),
+		done:     make([]chan struct{}, len(p.nodes)),
+	}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	defer r.cancel()
+
+	if p.concurrency > 0 {
+		r.sem = make(chan struct{}, p.concurrency)
+	}
+
+	report := &Report{Started: time.Now()}
+
+	for i := range p.nodes {
+		r.done[i] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for i := range p.nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(r.done[i])
+
+			r.results[i] = r.execute(i)
+		}(i)
+	}
+	wg.Wait()
+
+	report.Finished = time.Now()
+	report.Nodes = r.results
+
+	return report, nil
+}
+
+// run holds the state of a single execution of a pipeline.
+type run struct {
+	pipeline *Pipeline
+	ctx      context.Context
+	cancel   context.CancelFunc
+	sem      chan struct{}
+
+	results []*NodeResult
+	done    []chan struct{}
+
+	mu      sync.Mutex
+	stopped bool
+}
+
+func (r *run) execute(i int) *NodeResult {
+	node := r.pipeline.nodes[i]
+	result := &NodeResult{Name: node.Name, Status: StatusSkipped}
+
+	// results of upstream nodes are safe to read once their done channel is closed
+	upstream := map[string]*puppetmaster.Job{}
+	for _, dep := range node.DependsOn {
+		d := r.pipeline.index[dep]
+		<-r.done[d]
+
+		if r.results[d].Status != StatusSucceeded {
+			result.Err = ErrUpstreamFailed
+			return result
+		}
+		upstream[dep] = r.results[d].Job
+	}
+
+	if r.sem != nil {
+		select {
+		case r.sem <- struct{}{}:
+			defer func() { <-r.sem }()
+		case <-r.ctx.Done():
+		}
+	}
+
+	if r.isStopped() {
+		result.Err = ErrPipelineStopped
+		return result
+	}
+
+	if err := r.ctx.Err(); err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Started = time.Now()
+	result.Job, result.Err = r.build(node, upstream)
+	result.Finished = time.Now()
+
+	if result.Err != nil {
+		result.Status = StatusFailed
+		r.fail()
+		return result
+	}
+
+	result.Status = StatusSucceeded
+
+	return result
+}
+
+func (r *run) build(node Node, upstream map[string]*puppetmaster.Job) (*puppetmaster.Job, error) {
+	jobRequest, err := node.Build(upstream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build job request: %v", err)
+	}
+
+	job, err := r.pipeline.executor.ExecuteSyncWithOptions(r.ctx, jobRequest, nil)
+	if err != nil {
+		return job, err
+	}
+
+	if job.Status == puppetmaster.StatusCancelled {
+		return job, fmt.Errorf("job %v was cancelled", job.UUID)
+	}
+
+	if job.Error != "" {
+		return job, fmt.Errorf("job %v failed: %v", job.UUID, job.Error)
+	}
+
+	return job, nil
+}
+
+func (r *run) fail() {
+	if r.pipeline.policy != StopOnFailure {
+		return
+	}
+
+	r.mu.Lock()
+	r.stopped = true
+	r.mu.Unlock()
+
+	r.cancel()
+}
+
+func (r *run) isStopped() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.stopped
+}
+
+// String renders the report as a human readable summary, one line per node.
+func (r *Report) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "pipeline took %v\n", r.Finished.Sub(r.Started).Round(time.Millisecond))
+
+	for _, n := range r.Nodes {
+		fmt.Fprintf(b, "%s: %s", n.Name, n.Status)
+		if n.Status != StatusSkipped {
+			fmt.Fprintf(b, " in %v", n.Finished.Sub(n.Started).Round(time.Millisecond))
+		}
+		if n.Err != nil {
+			fmt.Fprintf(b, " (%v)", n.Err)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,203 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+// fakeExecutor returns a done job with the request's vars as results. Jobs with the var "fail" fail, jobs with the
+// var "block" block until cancelled.
+type fakeExecutor struct {
+	mu       sync.Mutex
+	executed []string
+}
+
+func (f *fakeExecutor) ExecuteSyncWithOptions(ctx context.Context, jobRequest *puppetmaster.JobRequest, opts *puppetmaster.SyncOptions) (*puppetmaster.Job, error) {
+	f.mu.Lock()
+	f.executed = append(f.executed, jobRequest.Code)
+	f.mu.Unlock()
+
+	if jobRequest.Vars["block"] != "" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	job := &puppetmaster.Job{UUID: jobRequest.Code, Status: puppetmaster.StatusDone, Results: map[string]interface{}{}}
+	for k, v := range jobRequest.Vars {
+		job.Results[k] = v
+	}
+
+	if jobRequest.Vars["fail"] != "" {
+		job.Error = "failed on purpose"
+	}
+
+	return job, nil
+}
+
+func node(name string, vars map[string]string, deps ...string) Node {
+	return Node{
+		Name:      name,
+		DependsOn: deps,
+		Build: func(upstream map[string]*puppetmaster.Job) (*puppetmaster.JobRequest, error) {
+			req := &puppetmaster.JobRequest{Code: name, Vars: map[string]string{}}
+			for k, v := range vars {
+				req.Vars[k] = v
+			}
+
+			// pass the cookie of the login job on
+			if login, ok := upstream["login"]; ok {
+				req.Vars["cookie"] = login.Results["cookie"].(string)
+			}
+
+			return req, nil
+		},
+	}
+}
+
+func newTestPipeline(t *testing.T, executor puppetmaster.Executor, nodes ...Node) *Pipeline {
+	p := New(executor)
+	for _, n := range nodes {
+		if err := p.Add(n); err != nil {
+			t.Fatalf("failed to add node %v: %v", n.Name, err)
+		}
+	}
+
+	return p
+}
+
+func TestPipeline_Run(t *testing.T) {
+	executor := &fakeExecutor{}
+	p := newTestPipeline(t, executor,
+		node("scrape-a", nil, "login"),
+		node("scrape-b", nil, "login"),
+		node("login", map[string]string{"cookie": "session=1"}),
+		node("merge", nil, "scrape-a", "scrape-b"),
+	)
+
+	report, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatalf("failed to run pipeline: %v", err)
+	}
+
+	if !report.Succeeded() {
+		t.Fatalf("Expected pipeline to succeed, got\n%v", report)
+	}
+
+	if executor.executed[0] != "login" || executor.executed[3] != "merge" {
+		t.Errorf("Expected login first and merge last, got %v", executor.executed)
+	}
+
+	for _, name := range []string{"scrape-a", "scrape-b"} {
+		if cookie := report.Node(name).Job.Results["cookie"]; cookie != "session=1" {
+			t.Errorf("Expected %v to receive the login cookie, got %v", name, cookie)
+		}
+	}
+
+	if report.Nodes[0].Name != "scrape-a" {
+		t.Errorf("Expected results in the order nodes were added, got %v first", report.Nodes[0].Name)
+	}
+}
+
+func TestPipeline_FailurePolicy(t *testing.T) {
+	cases := []struct {
+		policy FailurePolicy
+		exp    map[string]Status
+	}{
+		{
+			policy: StopOnFailure,
+			exp: map[string]Status{
+				"broken": StatusFailed, "after-broken": StatusSkipped, "after-blocking": StatusSkipped,
+			},
+		},
+		{
+			policy: ContinueOnFailure,
+			exp: map[string]Status{
+				"broken": StatusFailed, "after-broken": StatusSkipped, "independent": StatusSucceeded, "after-independent": StatusSucceeded,
+			},
+		},
+	}
+
+	for i, c := range cases {
+		p := newTestPipeline(t, &fakeExecutor{},
+			node("broken", map[string]string{"fail": "1"}),
+			node("after-broken", nil, "broken"),
+		)
+
+		if c.policy == StopOnFailure {
+			_ = p.Add(node("blocking", map[string]string{"block": "1"}))
+			_ = p.Add(node("after-blocking", nil, "blocking"))
+		} else {
+			_ = p.Add(node("independent", nil))
+			_ = p.Add(node("after-independent", nil, "independent"))
+		}
+		p.SetFailurePolicy(c.policy)
+
+		report, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatalf("case %d: failed to run pipeline: %v", i, err)
+		}
+
+		for name, status := range c.exp {
+			if res := report.Node(name); res.Status != status {
+				t.Errorf("case %d: Expected %v to be %v, got %v (%v)", i, name, status, res.Status, res.Err)
+			}
+		}
+
+		// depending on timing, the blocking node is either cancelled while running or never started
+		if res := report.Node("blocking"); res != nil && res.Status == StatusSucceeded {
+			t.Errorf("case %d: Expected blocking node to be stopped, got %v", i, res.Status)
+		}
+
+		if res := report.Node("after-broken"); !errors.Is(res.Err, ErrUpstreamFailed) {
+			t.Errorf("case %d: Expected ErrUpstreamFailed, got %v", i, res.Err)
+		}
+
+		if !strings.Contains(report.String(), "broken: failed") {
+			t.Errorf("case %d: Expected report to mention failed node, got\n%v", i, report)
+		}
+	}
+}
+
+func TestPipeline_Validate(t *testing.T) {
+	cases := []struct {
+		nodes []Node
+		err   string
+	}{
+		{nodes: []Node{node("a", nil, "missing")}, err: `unknown node "missing"`},
+		{nodes: []Node{node("a", nil, "b"), node("b", nil, "c"), node("c", nil, "a")}, err: "dependency cycle"},
+		{nodes: []Node{node("a", nil, "a")}, err: "dependency cycle"},
+		{nodes: []Node{node("a", nil), node("b", nil, "a")}, err: ""},
+	}
+
+	for i, c := range cases {
+		err := newTestPipeline(t, &fakeExecutor{}, c.nodes...).Validate()
+		if c.err == "" && err != nil {
+			t.Errorf("case %d: Expected pipeline to be valid, got %v", i, err)
+		}
+
+		if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("case %d: Expected error containing %q, got %v", i, c.err, err)
+		}
+	}
+}
+
+func TestPipeline_Add(t *testing.T) {
+	p := New(&fakeExecutor{})
+
+	if err := p.Add(Node{Build: node("a", nil).Build}); err != ErrEmptyName {
+		t.Errorf("Expected ErrEmptyName, got %v", err)
+	}
+
+	if err := p.Add(node("a", nil)); err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	if err := p.Add(node("a", nil)); err == nil {
+		t.Error("Expected duplicate node to be rejected")
+	}
+}
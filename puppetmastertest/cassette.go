@@ -0,0 +1,342 @@
+package puppetmastertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+// ErrNoInteraction is thrown when a replaying Recorder receives a request that was not recorded.
+var ErrNoInteraction = errors.New("no recorded interaction matches the request")
+
+// scrubbed replaces secrets in recorded interactions.
+const scrubbed = "<scrubbed>"
+
+// Mode decides whether a Recorder records or replays interactions.
+type Mode int
+
+// possible recorder modes
+const (
+	// ModeReplay serves recorded responses and fails requests that were not recorded, without using the network.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests to the puppet master and records them with their responses.
+	ModeRecord
+)
+
+// Cassette is the content of a cassette file.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request with its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest identifies a request. Its body is stored as canonical JSON, so requests only differing in the
+// order of keys, e.g. of job vars, match.
+type RecordedRequest struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// RecordedResponse is the response served for a request.
+type RecordedResponse struct {
+	StatusCode int                 `json:"status_code"`
+	Header     map[string][]string `json:"header,omitempty"`
+	Body       json.RawMessage     `json:"body,omitempty"`
+	// Text holds bodies that are no JSON.
+	Text string `json:"text,omitempty"`
+}
+
+// sensitiveHeaders are never recorded.
+var sensitiveHeaders = []string{"Authorization", "Set-Cookie", "Cookie"}
+
+// Recorder is an http.RoundTripper recording or replaying the interactions of a puppetmaster.Client with a cassette
+// file. Requests are matched by method, URL without scheme and host, and canonical JSON body. Every recorded
+// interaction is replayed once, in the recorded order, so polling a job replays the same sequence of states.
+// Bearer tokens are scrubbed from recorded URLs and bodies and the authorization header is not recorded at all.
+type Recorder struct {
+	path      string
+	mode      Mode
+	transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+	secrets  map[string]bool
+}
+
+var _ http.RoundTripper = (*Recorder)(nil)
+
+// NewRecorder returns a Recorder for the cassette file at path. In ModeReplay the cassette is loaded, in ModeRecord
+// requests are sent through transport, or http.DefaultTransport if it is nil, and the cassette is written by Save().
+func NewRecorder(path string, mode Mode, transport http.RoundTripper) (*Recorder, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	r := &Recorder{
+		path:      path,
+		mode:      mode,
+		transport: transport,
+		secrets:   map[string]bool{},
+	}
+
+	if mode == ModeReplay {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %v", err)
+		}
+
+		if err := json.Unmarshal(b, &r.cassette); err != nil {
+			return nil, fmt.Errorf("failed to decode cassette %v: %v", path, err)
+		}
+
+		// the cassette file is indented, requests are matched on compact bodies
+		for i := range r.cassette.Interactions {
+			req := &r.cassette.Interactions[i].Request
+			if req.Body, err = canonicalJSON(req.Body); err != nil {
+				return nil, fmt.Errorf("failed to decode request body of interaction %d: %v", i, err)
+			}
+		}
+
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, req, err := r.recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, recorded)
+	}
+
+	res, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request:  recorded,
+		Response: r.recordResponse(res, body),
+	})
+
+	return res, nil
+}
+
+// Save writes the recorded interactions to the cassette file. It does nothing in ModeReplay.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(r.path, append([]byte(r.scrub(string(b))), '\n'), 0644)
+}
+
+// Unused returns the recorded requests that were not replayed, e.g. to check that a test sent all of them.
+func (r *Recorder) Unused() []RecordedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []RecordedRequest
+	for i, used := range r.used {
+		if !used {
+			unused = append(unused, r.cassette.Interactions[i].Request)
+		}
+	}
+
+	return unused
+}
+
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !interaction.Request.matches(recorded) {
+			continue
+		}
+		r.used[i] = true
+
+		res := &http.Response{
+			StatusCode: interaction.Response.StatusCode,
+			Status:     fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{},
+			Request:    req,
+		}
+
+		for k, v := range interaction.Response.Header {
+			res.Header[k] = append([]string{}, v...)
+		}
+
+		body := []byte(interaction.Response.Text)
+		if len(interaction.Response.Body) > 0 {
+			body = interaction.Response.Body
+		}
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		res.ContentLength = int64(len(body))
+
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("%w: %v %v %s", ErrNoInteraction, recorded.Method, recorded.URL, recorded.Body)
+}
+
+// recordRequest converts req into its recorded form. As reading the body consumes it, a copy of req with a fresh body
+// is returned for sending.
+func (r *Recorder) recordRequest(req *http.Request) (RecordedRequest, *http.Request, error) {
+	recorded := RecordedRequest{Method: req.Method, URL: req.URL.RequestURI()}
+
+	r.mu.Lock()
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		r.secrets[strings.TrimPrefix(auth, "Bearer ")] = true
+	}
+	recorded.URL = r.scrub(recorded.URL)
+	r.mu.Unlock()
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, req, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return recorded, nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	if recorded.Body, err = canonicalJSON(body); err != nil {
+		// keep bodies that are no JSON as JSON string
+		recorded.Body, _ = json.Marshal(string(body))
+	}
+
+	r.mu.Lock()
+	recorded.Body = json.RawMessage(r.scrub(string(recorded.Body)))
+	r.mu.Unlock()
+
+	return recorded, req, nil
+}
+
+func (r *Recorder) recordResponse(res *http.Response, body []byte) RecordedResponse {
+	recorded := RecordedResponse{StatusCode: res.StatusCode, Header: map[string][]string{}}
+
+	for k, v := range res.Header {
+		recorded.Header[k] = v
+	}
+	for _, h := range sensitiveHeaders {
+		delete(recorded.Header, h)
+	}
+	// the body is re-encoded, the length is set on replay
+	delete(recorded.Header, "Content-Length")
+
+	var err error
+	if recorded.Body, err = canonicalJSON(body); err != nil {
+		recorded.Text = string(body)
+	}
+
+	return recorded
+}
+
+// scrub replaces all tokens seen in requests. r.mu has to be held.
+func (r *Recorder) scrub(s string) string {
+	for secret := range r.secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, scrubbed)
+		}
+	}
+
+	return s
+}
+
+func (req RecordedRequest) matches(other RecordedRequest) bool {
+	return req.Method == other.Method && req.URL == other.URL && bytes.Equal(req.Body, other.Body)
+}
+
+// canonicalJSON re-encodes JSON with sorted keys and without insignificant whitespace.
+func canonicalJSON(b []byte) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// UseCassette makes client record its interactions to testdata/<name>.cassette.json when the tests run with
+// -puppetmastertest.update and replay them otherwise. The cassette is written when the test finished.
+func UseCassette(t testing.TB, client *puppetmaster.Client, name string) *Recorder {
+	t.Helper()
+
+	mode := ModeReplay
+	if *update {
+		mode = ModeRecord
+	}
+
+	recorder, err := NewRecorder(filepath.Join(goldenDir, name+".cassette.json"), mode, nil)
+	if err != nil {
+		t.Fatalf("failed to load cassette, run the test with -puppetmastertest.update to record it: %v", err)
+	}
+
+	client.SetHTTPClient(&http.Client{Transport: recorder})
+
+	t.Cleanup(func() {
+		if err := recorder.Save(); err != nil {
+			t.Errorf("failed to save cassette: %v", err)
+		}
+	})
+
+	return recorder
+}
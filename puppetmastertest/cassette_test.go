@@ -0,0 +1,140 @@
+package puppetmastertest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+const jobResponse = `{"data": {"uuid": "job-1", "status": %q, "code": "foo", "error": %q}}`
+
+// statusHandler answers job requests with queued first and done afterwards. It echoes the token in the error, so
+// scrubbing can be checked.
+func statusHandler() http.Handler {
+	calls := 0
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		status := puppetmaster.StatusQueued
+		if calls > 0 {
+			status = puppetmaster.StatusDone
+		}
+		calls++
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Set-Cookie", "session=secret")
+		if req.Method == http.MethodPost {
+			rw.WriteHeader(201)
+		}
+		fmt.Fprintf(rw, jobResponse, status, req.Header.Get("Authorization"))
+	})
+}
+
+func newCassetteClient(t *testing.T, baseURL, token string, recorder *Recorder) *puppetmaster.Client {
+	client, err := puppetmaster.NewClient(baseURL, token)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	client.SetHTTPClient(&http.Client{Transport: recorder})
+
+	return client
+}
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.cassette.json")
+	server := httptest.NewServer(statusHandler())
+	jobRequest := &puppetmaster.JobRequest{Code: "foo", Vars: map[string]string{"b": "2", "a": "1"}}
+
+	recorder, err := NewRecorder(path, ModeRecord, nil)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+
+	client := newCassetteClient(t, server.URL, "secret-token", recorder)
+	if _, err := client.CreateJob(jobRequest); err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+
+	if job, err := client.GetJob("job-1"); err != nil || job.Status != puppetmaster.StatusDone {
+		t.Fatalf("Expected done job, got %+v (%v)", job, err)
+	}
+
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("failed to save cassette: %v", err)
+	}
+	server.Close()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read cassette: %v", err)
+	}
+
+	for _, secret := range []string{"secret-token", "session=secret"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("Expected %q to be scrubbed from cassette:\n%s", secret, b)
+		}
+	}
+
+	// replay without the server, with another token
+	recorder, err = NewRecorder(path, ModeReplay, nil)
+	if err != nil {
+		t.Fatalf("failed to load cassette: %v", err)
+	}
+
+	client = newCassetteClient(t, server.URL, "other-token", recorder)
+	job, err := client.CreateJob(jobRequest)
+	if err != nil || job.Status != puppetmaster.StatusQueued || job.Error != "Bearer "+scrubbed {
+		t.Fatalf("Expected replayed queued job, got %+v (%v)", job, err)
+	}
+
+	if job, err := client.GetJob("job-1"); err != nil || job.Status != puppetmaster.StatusDone {
+		t.Fatalf("Expected replayed done job, got %+v (%v)", job, err)
+	}
+
+	if unused := recorder.Unused(); len(unused) != 0 {
+		t.Errorf("Expected all interactions to be replayed, got %v", unused)
+	}
+
+	// every interaction is replayed once only
+	if _, err := client.GetJob("job-1"); !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("Expected ErrNoInteraction, got %v", err)
+	}
+
+	if _, err := client.CreateJob(&puppetmaster.JobRequest{Code: "bar"}); !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("Expected ErrNoInteraction for other code, got %v", err)
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	cases := []struct {
+		b1, b2 string
+		equal  bool
+	}{
+		{b1: `{"code":"foo","vars":{"a":"1","b":"2"}}`, b2: "{\n  \"vars\": {\"b\": \"2\", \"a\": \"1\"},\n  \"code\": \"foo\"\n}", equal: true},
+		{b1: `{"n": 1.0}`, b2: `{"n": 1.0}`, equal: true},
+		{b1: `{"code":"foo"}`, b2: `{"code":"bar"}`, equal: false},
+		{b1: ``, b2: `  `, equal: true},
+	}
+
+	for i, c := range cases {
+		c1, err := canonicalJSON([]byte(c.b1))
+		if err != nil {
+			t.Fatalf("case %d: failed to canonicalize %q: %v", i, c.b1, err)
+		}
+
+		c2, err := canonicalJSON([]byte(c.b2))
+		if err != nil {
+			t.Fatalf("case %d: failed to canonicalize %q: %v", i, c.b2, err)
+		}
+
+		if (string(c1) == string(c2)) != c.equal {
+			t.Errorf("case %d: Expected equality %v of %s and %s", i, c.equal, c1, c2)
+		}
+	}
+}
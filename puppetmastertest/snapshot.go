@@ -0,0 +1,177 @@
+// Package puppetmastertest provides helpers for testing code and scripts driving puppet-master jobs.
+package puppetmastertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+// update is prefixed with the package name, so it does not clash with the -update flag tests commonly define
+// themselves.
+var update = flag.Bool("puppetmastertest.update", false, "rewrite golden files and record cassettes of puppetmastertest")
+
+// goldenDir is the directory golden files are stored in, relative to the package under test.
+var goldenDir = "testdata"
+
+// placeholders replace values that change on every run, so they do not break snapshots
+const (
+	uuidPlaceholder      = "<uuid>"
+	timestampPlaceholder = "<timestamp>"
+)
+
+var (
+	uuidPattern      = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	timestampPattern = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?\b`)
+)
+
+// snapshot is the content of a golden file.
+type snapshot struct {
+	Status  string                 `json:"status"`
+	Error   string                 `json:"error,omitempty"`
+	Results map[string]interface{} `json:"results"`
+	Logs    []snapshotLog          `json:"logs"`
+}
+
+type snapshotLog struct {
+	Level   puppetmaster.LogLevel `json:"level"`
+	Message string                `json:"message"`
+}
+
+// Snapshot compares the status, error, results and logs of job to the golden file testdata/<test name>.golden.json
+// and fails the test with the changes if they differ. UUIDs and timestamps, including those within results and log
+// messages, are replaced with placeholders first. Running the tests with -puppetmastertest.update writes the golden
+// file instead. The options are passed to puppetmaster.Diff(), e.g. to ignore further volatile results.
+func Snapshot(t testing.TB, job *puppetmaster.Job, opts ...puppetmaster.CompareOption) {
+	t.Helper()
+
+	actual, err := normalize(job)
+	if err != nil {
+		t.Fatalf("failed to normalize job: %v", err)
+	}
+
+	path := filepath.Join(goldenDir, goldenName(t.Name()))
+
+	if *update {
+		if err := writeGolden(path, actual); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("golden file %v does not exist, run the test with -puppetmastertest.update to create it", path)
+	}
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+
+	expected := &snapshot{}
+	if err := json.Unmarshal(b, expected); err != nil {
+		t.Fatalf("failed to decode golden file %v: %v", path, err)
+	}
+
+	changes := puppetmaster.Diff(expected.job(), actual.job(), opts...)
+	if len(changes) == 0 {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	_ = puppetmaster.WriteChangesText(buf, changes)
+	t.Errorf("job does not match golden file %v, run the test with -puppetmastertest.update to accept the changes:\n%s", path, buf)
+}
+
+// normalize converts the job to a snapshot. The results are passed through JSON, so they compare equal to the decoded
+// golden file.
+func normalize(job *puppetmaster.Job) (*snapshot, error) {
+	s := &snapshot{
+		Status: job.Status,
+		Error:  replaceVolatile(job.Error),
+		Logs:   []snapshotLog{},
+	}
+
+	b, err := json.Marshal(job.Results)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &s.Results); err != nil {
+		return nil, err
+	}
+
+	if s.Results != nil {
+		s.Results = normalizeValue(s.Results).(map[string]interface{})
+	}
+
+	for _, l := range job.Logs {
+		s.Logs = append(s.Logs, snapshotLog{Level: l.Level, Message: replaceVolatile(l.Message)})
+	}
+
+	return s, nil
+}
+
+func normalizeValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, child := range value {
+			value[k] = normalizeValue(child)
+		}
+	case []interface{}:
+		for i, child := range value {
+			value[i] = normalizeValue(child)
+		}
+	case string:
+		return replaceVolatile(value)
+	}
+
+	return v
+}
+
+func replaceVolatile(s string) string {
+	s = uuidPattern.ReplaceAllString(s, uuidPlaceholder)
+
+	return timestampPattern.ReplaceAllString(s, timestampPlaceholder)
+}
+
+func (s *snapshot) job() *puppetmaster.Job {
+	job := &puppetmaster.Job{Status: s.Status, Error: s.Error, Results: s.Results}
+	for _, l := range s.Logs {
+		job.Logs = append(job.Logs, puppetmaster.Log{Level: l.Level, Message: l.Message})
+	}
+
+	return job
+}
+
+// goldenName turns the name of a (sub)test into a file name.
+func goldenName(testName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, testName)
+
+	return name + ".golden.json"
+}
+
+func writeGolden(path string, s *snapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
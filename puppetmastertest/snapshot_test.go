@@ -0,0 +1,117 @@
+package puppetmastertest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+// tests importing this package commonly define their own -update flag, which panics if the package defined it, too
+var _ = flag.Bool("update", false, "flag of the package under test")
+
+// recordingTB records failures instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	name   string
+	errors []string
+	fatal  bool
+}
+
+func (r *recordingTB) Helper()      {}
+func (r *recordingTB) Name() string { return r.name }
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	r.fatal = true
+	runtime.Goexit()
+}
+
+// snapshotIn runs Snapshot in its own goroutine, so Fatalf can stop it like the testing package does.
+func snapshotIn(tb *recordingTB, job *puppetmaster.Job, opts ...puppetmaster.CompareOption) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Snapshot(tb, job, opts...)
+	}()
+	<-done
+}
+
+func scrapeJob(price float64, at time.Time) *puppetmaster.Job {
+	return &puppetmaster.Job{
+		UUID:      "9a0e3c9e-3f1c-4a43-9d3c-0f7d2b6b2f11",
+		Status:    puppetmaster.StatusDone,
+		CreatedAt: at,
+		Results: map[string]interface{}{
+			"price":      price,
+			"fetched_at": at.Format(time.RFC3339),
+			"items":      []interface{}{map[string]interface{}{"id": "5c1f6a5e-0b5b-4c07-9e8c-51f4f1c2f0ab"}},
+		},
+		Logs: []puppetmaster.Log{
+			{Time: at, Level: puppetmaster.LevelInfo, Message: "fetched at " + at.Format(time.RFC3339Nano)},
+		},
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	defer func(dir string) { goldenDir = dir }(goldenDir)
+	goldenDir = t.TempDir()
+
+	now := time.Now()
+	tb := &recordingTB{name: "TestScrape/shop one"}
+
+	snapshotIn(tb, scrapeJob(9.99, now))
+	if !tb.fatal || !strings.Contains(tb.errors[0], "-puppetmastertest.update") {
+		t.Fatalf("Expected missing golden file to fail with a hint to -puppetmastertest.update, got %v", tb.errors)
+	}
+
+	*update = true
+	tb = &recordingTB{name: tb.name}
+	snapshotIn(tb, scrapeJob(9.99, now))
+	*update = false
+
+	if len(tb.errors) > 0 {
+		t.Fatalf("Expected golden file to be written, got %v", tb.errors)
+	}
+
+	b, err := os.ReadFile(filepath.Join(goldenDir, "TestScrape_shop_one.golden.json"))
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+
+	golden := string(b)
+	for _, volatile := range []string{"9a0e3c9e", "5c1f6a5e", now.Format("2006-01-02")} {
+		if strings.Contains(golden, volatile) {
+			t.Errorf("Expected %q to be replaced in golden file:\n%s", volatile, golden)
+		}
+	}
+
+	// another run at another time matches
+	tb = &recordingTB{name: tb.name}
+	snapshotIn(tb, scrapeJob(9.99, now.Add(48*time.Hour)))
+	if len(tb.errors) > 0 {
+		t.Errorf("Expected snapshot to match, got %v", tb.errors)
+	}
+
+	tb = &recordingTB{name: tb.name}
+	snapshotIn(tb, scrapeJob(12.5, now))
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "~ results.price: 9.99 -> 12.5") {
+		t.Errorf("Expected changed price to be reported, got %v", tb.errors)
+	}
+
+	tb = &recordingTB{name: tb.name}
+	snapshotIn(tb, scrapeJob(12.5, now), puppetmaster.IgnorePaths("results.price"))
+	if len(tb.errors) > 0 {
+		t.Errorf("Expected ignored price to match, got %v", tb.errors)
+	}
+}
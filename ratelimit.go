@@ -0,0 +1,76 @@
+package puppetmaster
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SetRateLimit limits the client to send at most perSecond requests per second on average, allowing bursts of up to
+// burst requests. A perSecond of 0 disables the limit.
+func (c *Client) SetRateLimit(perSecond float64, burst int) {
+	if perSecond <= 0 {
+		c.limiter = nil
+		return
+	}
+
+	c.limiter = newRateLimiter(perSecond, burst)
+}
+
+// rateLimiter is a token bucket refilled with perSecond tokens per second.
+type rateLimiter struct {
+	perSecond float64
+	burst     float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		perSecond: perSecond,
+		burst:     float64(burst),
+		tokens:    float64(burst),
+		last:      time.Now(),
+	}
+}
+
+// wait blocks until a request may be sent or ctx is done.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	for {
+		delay := r.reserve(time.Now())
+		if delay == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// reserve takes a token and returns 0, or returns how long to wait until the next token is available.
+func (r *rateLimiter) reserve(now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens += now.Sub(r.last).Seconds() * r.perSecond
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+
+	if r.tokens >= 1 {
+		r.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - r.tokens) / r.perSecond * float64(time.Second))
+}
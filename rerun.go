@@ -0,0 +1,50 @@
+package puppetmaster
+
+import (
+	"context"
+	"fmt"
+)
+
+// ToRequest returns a JobRequest with the code, modules and vars of the job. The maps are copied, so the request
+// can be changed without touching the job.
+func (j *Job) ToRequest() *JobRequest {
+	return &JobRequest{
+		Code:    j.Code,
+		Modules: copyStringMap(j.Modules),
+		Vars:    copyStringMap(j.Vars),
+	}
+}
+
+// Rerun fetches the job with the given UUID and submits it again, with varOverrides replacing or adding vars, and
+// returns the new job. The puppet master has no field to link a job to the one it was re-run from, so the caller has
+// to keep track of the link if it is needed.
+func (c *Client) Rerun(ctx context.Context, uuid string, varOverrides map[string]string) (*Job, error) {
+	original, err := c.GetJobContext(ctx, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch job to re-run: %w", err)
+	}
+
+	jobRequest := original.ToRequest()
+
+	if jobRequest.Vars == nil {
+		jobRequest.Vars = map[string]string{}
+	}
+	for k, v := range varOverrides {
+		jobRequest.Vars[k] = v
+	}
+
+	return c.CreateJobContext(ctx, jobRequest)
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
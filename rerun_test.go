@@ -0,0 +1,86 @@
+package puppetmaster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestJob_ToRequest(t *testing.T) {
+	job := &Job{
+		UUID:    "uuid",
+		Code:    "test",
+		Vars:    map[string]string{"page": "test"},
+		Modules: map[string]string{"shared": "test"},
+	}
+
+	req := job.ToRequest()
+	req.Vars["page"] = "changed"
+
+	if req.Code != job.Code || req.Modules["shared"] != "test" {
+		t.Errorf("Expected request to carry the job's content, got %+v", req)
+	}
+
+	if job.Vars["page"] != "test" {
+		t.Error("Expected changing the request not to touch the job")
+	}
+
+	if req.Hash() != (&Job{Code: "test", Vars: map[string]string{"page": "changed"}, Modules: job.Modules}).ToRequest().Hash() {
+		t.Error("Expected equal content to produce equal hashes")
+	}
+}
+
+func TestClient_Rerun(t *testing.T) {
+	original := readTestData(t, "get-job-response.json")
+
+	var created *JobRequest
+	var body map[string]interface{}
+	c := newTestClient(t, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet {
+			_, _ = rw.Write(original)
+			return
+		}
+
+		b, _ := io.ReadAll(req.Body)
+		created = &JobRequest{}
+		if err := json.Unmarshal(b, created); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		_ = json.Unmarshal(b, &body)
+
+		rw.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(rw).Encode(&JobResponse{Data: Job{UUID: "new", Code: created.Code, Vars: created.Vars}})
+	}))
+
+	job, err := c.client.Rerun(context.Background(), "73e3a9b5-81c8-4743-9a7e-e80474c1b6e3", map[string]string{"page": "http://example.com", "extra": "1"})
+	if err != nil {
+		t.Fatalf("failed to re-run job: %v", err)
+	}
+
+	if job.UUID != "new" {
+		t.Errorf("Expected new job, got %+v", job)
+	}
+
+	if _, ok := body["rerun_of"]; ok {
+		t.Errorf("Expected no link to the original to be sent, got %v", body)
+	}
+
+	if created.Vars["page"] != "http://example.com" || created.Vars["extra"] != "1" {
+		t.Errorf("Expected vars to be overridden, got %v", created.Vars)
+	}
+
+	if created.Modules["shared"] == "" {
+		t.Errorf("Expected modules to be copied, got %v", created.Modules)
+	}
+}
+
+func TestClient_RerunNotFound(t *testing.T) {
+	c := newTestClient(t, dumbHandler(404, nil))
+
+	if _, err := c.client.Rerun(context.Background(), "uuid", nil); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+}
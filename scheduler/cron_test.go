@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	base := time.Date(2018, 8, 13, 11, 55, 17, 0, time.UTC) // a monday
+
+	cases := []struct {
+		expr string
+		exp  time.Time
+	}{
+		{expr: "* * * * *", exp: time.Date(2018, 8, 13, 11, 56, 0, 0, time.UTC)},
+		{expr: "@hourly", exp: time.Date(2018, 8, 13, 12, 0, 0, 0, time.UTC)},
+		{expr: "*/15 * * * *", exp: time.Date(2018, 8, 13, 12, 0, 0, 0, time.UTC)},
+		{expr: "5,10 9-17 * * *", exp: time.Date(2018, 8, 13, 12, 5, 0, 0, time.UTC)},
+		{expr: "0 0 * * *", exp: time.Date(2018, 8, 14, 0, 0, 0, 0, time.UTC)},
+		{expr: "30 8 * * sat", exp: time.Date(2018, 8, 18, 8, 30, 0, 0, time.UTC)},
+		{expr: "0 0 * * 7", exp: time.Date(2018, 8, 19, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 1 jan *", exp: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 12 1 * fri", exp: time.Date(2018, 8, 17, 12, 0, 0, 0, time.UTC)},
+		{expr: "0 0 29 2 *", exp: time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 30 2 *", exp: time.Time{}},
+		{expr: "@every 90m", exp: time.Date(2018, 8, 13, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for i, c := range cases {
+		s, err := ParseCron(c.expr)
+		if err != nil {
+			t.Fatalf("case %d: failed to parse %q: %v", i, c.expr, err)
+		}
+
+		if next := s.Next(base); !next.Equal(c.exp) {
+			t.Errorf("case %d: Expected %q to activate at %v, got %v", i, c.expr, c.exp, next)
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@every x", "@every 0s", "* * * foo *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("Expected %q to be invalid", expr)
+		}
+	}
+}
+
+func TestEvery(t *testing.T) {
+	base := time.Date(2018, 8, 13, 11, 55, 17, 0, time.UTC)
+
+	schedule, err := Every(time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create schedule: %v", err)
+	}
+
+	if next := schedule.Next(base); !next.Equal(time.Date(2018, 8, 13, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected next full hour, got %v", next)
+	}
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err := Every(interval); !errors.Is(err, ErrInvalidInterval) {
+			t.Errorf("Expected %v to be invalid, got %v", interval, err)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Package scheduler submits puppet-master jobs on recurring schedules, given as cron expressions or intervals.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+var (
+	// ErrAlreadyStarted is thrown when entries are added to or Start() is called on a running scheduler.
+	ErrAlreadyStarted = errors.New("scheduler was already started")
+
+	// ErrEmptyName is thrown when an Entry without a name is added.
+	ErrEmptyName = errors.New("entry name may not be empty")
+
+	// ErrSkipped is passed to the handler for runs skipped because the previous run was still going on.
+	ErrSkipped = errors.New("run skipped, previous run still in progress")
+
+	// ErrInvalidInterval is thrown when Every() is called with an interval that is not positive.
+	ErrInvalidInterval = errors.New("interval must be positive")
+)
+
+// Clock abstracts time, so schedules can be tested without waiting.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// OverlapPolicy decides what happens when a run is due while the previous run of the same entry is still going on.
+type OverlapPolicy int
+
+// possible overlap policies
+const (
+	// OverlapSkip drops the new run and passes ErrSkipped to the handler.
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue starts the new run once the previous one finished.
+	OverlapQueue
+	// OverlapCancelPrevious cancels the previous run and starts the new one.
+	OverlapCancelPrevious
+)
+
+// Entry describes a recurring job.
+type Entry struct {
+	// Name identifies the entry in runs.
+	Name string
+	// Schedule computes the activation times, see ParseCron() and Every().
+	Schedule Schedule
+	// Request builds the job to submit for the activation at the given time.
+	Request func(scheduled time.Time) (*puppetmaster.JobRequest, error)
+	// Handler receives the result of every run. It may be nil.
+	Handler func(Run)
+	// Overlap decides what happens when a run is due while the previous one is still going on.
+	Overlap OverlapPolicy
+	// Jitter delays every run by a random duration up to Jitter, to spread the load of many entries.
+	Jitter time.Duration
+	// LastRun is the activation time of the last run before the scheduler was started, e.g. loaded from a
+	// database. Activations between LastRun and the start are treated as missed.
+	LastRun time.Time
+	// MaxCatchUp is the number of missed activations executed after the scheduler fell behind or was started
+	// after LastRun. Missed activations beyond that are dropped. With 0, missed activations are dropped. Missed
+	// activations and the current one are executed one after another, regardless of Overlap, which only applies to
+	// them as a whole.
+	MaxCatchUp int
+}
+
+// Run is the result of a single activation of an Entry.
+type Run struct {
+	Entry     string
+	Scheduled time.Time
+	Started   time.Time
+	Finished  time.Time
+	Job       *puppetmaster.Job
+	Err       error
+}
+
+// Scheduler runs entries on their schedules.
+type Scheduler struct {
+	executor puppetmaster.Executor
+	clock    Clock
+	jitter   func(max time.Duration) time.Duration
+
+	mu      sync.Mutex
+	entries []*entryState
+	started bool
+	stop    chan struct{}
+	loops   sync.WaitGroup
+	runs    sync.WaitGroup
+
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+}
+
+type entryState struct {
+	Entry
+
+	// queue serializes runs for OverlapQueue and marks a running run for the other policies
+	queue chan struct{}
+
+	mu         sync.Mutex
+	cancelPrev context.CancelFunc
+	prevDone   chan struct{}
+}
+
+// New returns a new Scheduler submitting jobs through the given executor.
+func New(executor puppetmaster.Executor) *Scheduler {
+	return &Scheduler{
+		executor: executor,
+		clock:    realClock{},
+		jitter: func(max time.Duration) time.Duration {
+			return time.Duration(rand.Int63n(int64(max)))
+		},
+	}
+}
+
+// SetClock replaces the clock used to wait for activations. It has to be called before Start().
+func (s *Scheduler) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// Add registers an entry. Entries can only be added before Start().
+func (s *Scheduler) Add(entry Entry) error {
+	if strings.TrimSpace(entry.Name) == "" {
+		return ErrEmptyName
+	}
+
+	if entry.Schedule == nil || entry.Request == nil {
+		return fmt.Errorf("entry %q: schedule and request may not be nil", entry.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return ErrAlreadyStarted
+	}
+
+	s.entries = append(s.entries, &entryState{Entry: entry, queue: make(chan struct{}, 1)})
+
+	return nil
+}
+
+// Start starts scheduling all entries in the background. Runs use a context derived from ctx, so cancelling it
+// cancels all running jobs; use Stop() for a graceful shutdown.
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return ErrAlreadyStarted
+	}
+
+	s.started = true
+	s.stop = make(chan struct{})
+	s.runCtx, s.cancelRun = context.WithCancel(ctx)
+
+	for _, e := range s.entries {
+		s.loops.Add(1)
+		go s.loop(e)
+	}
+
+	return nil
+}
+
+// Stop stops scheduling new runs and waits for running ones to finish. When ctx is done before, the running jobs
+// are cancelled and Stop returns the context's error once they returned.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return nil
+	}
+	s.started = false
+	close(s.stop)
+	s.mu.Unlock()
+
+	s.loops.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+
+	defer s.cancelRun()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancelRun()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop(e *entryState) {
+	defer s.loops.Done()
+
+	now := s.clock.Now()
+	next := e.Schedule.Next(now)
+	if !e.LastRun.IsZero() {
+		// start with the first activation after the last run, so the missed ones are caught up right away
+		if first := e.Schedule.Next(e.LastRun); first.Before(next) {
+			next = first
+		}
+	}
+
+	for !next.IsZero() {
+		wait := next.Sub(s.clock.Now())
+		if e.Jitter > 0 {
+			wait += s.jitter(e.Jitter)
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-s.runCtx.Done():
+			return
+		case <-s.clock.After(wait):
+		}
+
+		// collect activations missed while waiting, e.g. after the process was suspended
+		now := s.clock.Now()
+		due := []time.Time{next}
+		for t := e.Schedule.Next(next); !t.IsZero() && !t.After(now); t = e.Schedule.Next(t) {
+			due = append(due, t)
+		}
+
+		current := due[len(due)-1]
+		missed := due[:len(due)-1]
+		if len(missed) > e.MaxCatchUp {
+			missed = missed[len(missed)-e.MaxCatchUp:]
+		}
+
+		s.trigger(e, append(missed, current))
+
+		next = e.Schedule.Next(current)
+	}
+}
+
+// trigger starts the runs of the given activations, usually just the current one, or the missed ones followed by the
+// current one when catching up. They are executed one after another, the overlap policy applies to them as a whole.
+func (s *Scheduler) trigger(e *entryState, scheduled []time.Time) {
+	switch e.Overlap {
+	case OverlapSkip:
+		select {
+		case e.queue <- struct{}{}:
+		default:
+			s.fail(e, scheduled, ErrSkipped)
+			return
+		}
+	case OverlapCancelPrevious:
+		e.mu.Lock()
+		cancel, done := e.cancelPrev, e.prevDone
+		e.mu.Unlock()
+
+		if cancel != nil {
+			cancel()
+			<-done
+		}
+
+		e.queue <- struct{}{}
+	}
+
+	// registered before starting the runs, so the next activation can always cancel them
+	ctx, cancel := context.WithCancel(s.runCtx)
+	done := make(chan struct{})
+
+	e.mu.Lock()
+	e.cancelPrev, e.prevDone = cancel, done
+	e.mu.Unlock()
+
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		defer close(done)
+		defer cancel()
+
+		if e.Overlap == OverlapQueue {
+			select {
+			case e.queue <- struct{}{}:
+			case <-ctx.Done():
+				s.fail(e, scheduled, ctx.Err())
+				return
+			}
+		}
+
+		defer func() {
+			e.mu.Lock()
+			if e.prevDone == done {
+				e.cancelPrev, e.prevDone = nil, nil
+			}
+			e.mu.Unlock()
+
+			<-e.queue
+		}()
+
+		for i, t := range scheduled {
+			if ctx.Err() != nil {
+				s.fail(e, scheduled[i:], ctx.Err())
+				return
+			}
+
+			s.execute(ctx, e, t)
+		}
+	}()
+}
+
+// fail reports the given activations as failed without running them.
+func (s *Scheduler) fail(e *entryState, scheduled []time.Time, err error) {
+	for _, t := range scheduled {
+		s.handle(e, Run{Entry: e.Name, Scheduled: t, Err: err})
+	}
+}
+
+func (s *Scheduler) execute(ctx context.Context, e *entryState, scheduled time.Time) {
+	run := Run{Entry: e.Name, Scheduled: scheduled, Started: s.clock.Now()}
+
+	jobRequest, err := e.Request(scheduled)
+	if err != nil {
+		run.Err = fmt.Errorf("failed to build job request: %v", err)
+	} else {
+		run.Job, run.Err = s.executor.ExecuteSyncWithOptions(ctx, jobRequest, nil)
+	}
+
+	run.Finished = s.clock.Now()
+	s.handle(e, run)
+}
+
+func (s *Scheduler) handle(e *entryState, run Run) {
+	if e.Handler != nil {
+		e.Handler(run)
+	}
+}
@@ -0,0 +1,339 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+// fakeClock only moves when advanced.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	var pending []fakeWaiter
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// blockUntil waits until n goroutines wait for the clock.
+func (c *fakeClock) blockUntil(t *testing.T, n int) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		waiting := len(c.waiters)
+		c.mu.Unlock()
+
+		if waiting >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("timed out waiting for %d clock waiters", n)
+}
+
+// fakeExecutor returns done jobs, or blocks until released or cancelled when block is set.
+type fakeExecutor struct {
+	block   bool
+	release chan struct{}
+	started chan struct{}
+}
+
+func newFakeExecutor(block bool) *fakeExecutor {
+	return &fakeExecutor{block: block, release: make(chan struct{}), started: make(chan struct{}, 10)}
+}
+
+func (f *fakeExecutor) ExecuteSyncWithOptions(ctx context.Context, jobRequest *puppetmaster.JobRequest, opts *puppetmaster.SyncOptions) (*puppetmaster.Job, error) {
+	f.started <- struct{}{}
+
+	if f.block {
+		select {
+		case <-f.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return &puppetmaster.Job{Status: puppetmaster.StatusDone, Vars: jobRequest.Vars}, nil
+}
+
+type runRecorder struct {
+	runs chan Run
+}
+
+func newRunRecorder() *runRecorder {
+	return &runRecorder{runs: make(chan Run, 10)}
+}
+
+func (r *runRecorder) handle(run Run) {
+	r.runs <- run
+}
+
+func (r *runRecorder) next(t *testing.T) Run {
+	select {
+	case run := <-r.runs:
+		return run
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for run")
+		return Run{}
+	}
+}
+
+func testRequest(scheduled time.Time) (*puppetmaster.JobRequest, error) {
+	return &puppetmaster.JobRequest{Code: "test", Vars: map[string]string{"scheduled": scheduled.Format(time.RFC3339)}}, nil
+}
+
+var testStart = time.Date(2018, 8, 13, 12, 0, 0, 0, time.UTC)
+
+var everyMinute = every(time.Minute)
+
+func newTestScheduler(t *testing.T, executor puppetmaster.Executor, entry Entry) (*Scheduler, *fakeClock) {
+	clock := newFakeClock(testStart)
+
+	s := New(executor)
+	s.SetClock(clock)
+
+	if err := s.Add(entry); err != nil {
+		t.Fatalf("failed to add entry: %v", err)
+	}
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start scheduler: %v", err)
+	}
+
+	return s, clock
+}
+
+func TestScheduler_Interval(t *testing.T) {
+	rec := newRunRecorder()
+	s, clock := newTestScheduler(t, newFakeExecutor(false), Entry{
+		Name:     "interval",
+		Schedule: everyMinute,
+		Request:  testRequest,
+		Handler:  rec.handle,
+	})
+
+	for i := 1; i <= 2; i++ {
+		clock.blockUntil(t, 1)
+		clock.Advance(time.Minute)
+
+		run := rec.next(t)
+		exp := testStart.Add(time.Duration(i) * time.Minute)
+		if run.Err != nil || !run.Scheduled.Equal(exp) || run.Job.Vars["scheduled"] != exp.Format(time.RFC3339) {
+			t.Errorf("run %d: Unexpected run %+v", i, run)
+		}
+	}
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("failed to stop scheduler: %v", err)
+	}
+}
+
+func TestScheduler_OverlapSkip(t *testing.T) {
+	executor := newFakeExecutor(true)
+	rec := newRunRecorder()
+	s, clock := newTestScheduler(t, executor, Entry{
+		Name:     "skip",
+		Schedule: everyMinute,
+		Request:  testRequest,
+		Handler:  rec.handle,
+		Overlap:  OverlapSkip,
+	})
+
+	clock.blockUntil(t, 1)
+	clock.Advance(time.Minute)
+	<-executor.started
+
+	clock.blockUntil(t, 1)
+	clock.Advance(time.Minute)
+
+	if run := rec.next(t); run.Err != ErrSkipped {
+		t.Errorf("Expected second run to be skipped, got %+v", run)
+	}
+
+	close(executor.release)
+	if run := rec.next(t); run.Err != nil {
+		t.Errorf("Expected first run to succeed, got %+v", run)
+	}
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("failed to stop scheduler: %v", err)
+	}
+}
+
+func TestScheduler_OverlapCancelPrevious(t *testing.T) {
+	executor := newFakeExecutor(true)
+	rec := newRunRecorder()
+	s, clock := newTestScheduler(t, executor, Entry{
+		Name:     "cancel",
+		Schedule: everyMinute,
+		Request:  testRequest,
+		Handler:  rec.handle,
+		Overlap:  OverlapCancelPrevious,
+	})
+
+	clock.blockUntil(t, 1)
+	clock.Advance(time.Minute)
+	<-executor.started
+
+	clock.blockUntil(t, 1)
+	clock.Advance(time.Minute)
+
+	if run := rec.next(t); run.Err != context.Canceled || !run.Scheduled.Equal(testStart.Add(time.Minute)) {
+		t.Errorf("Expected first run to be cancelled, got %+v", run)
+	}
+
+	<-executor.started
+	close(executor.release)
+	if run := rec.next(t); run.Err != nil {
+		t.Errorf("Expected second run to succeed, got %+v", run)
+	}
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("failed to stop scheduler: %v", err)
+	}
+}
+
+func TestScheduler_CatchUp(t *testing.T) {
+	cases := []struct {
+		overlap    OverlapPolicy
+		maxCatchUp int
+		runs       int
+	}{
+		{overlap: OverlapSkip, maxCatchUp: 0, runs: 1},
+		{overlap: OverlapSkip, maxCatchUp: 1, runs: 2},
+		{overlap: OverlapSkip, maxCatchUp: 5, runs: 3},
+		{overlap: OverlapQueue, maxCatchUp: 1, runs: 2},
+		{overlap: OverlapQueue, maxCatchUp: 5, runs: 3},
+		{overlap: OverlapCancelPrevious, maxCatchUp: 5, runs: 3},
+	}
+
+	for i, c := range cases {
+		rec := newRunRecorder()
+		s, _ := newTestScheduler(t, newFakeExecutor(false), Entry{
+			Name:       "catch-up",
+			Schedule:   everyMinute,
+			Request:    testRequest,
+			Handler:    rec.handle,
+			Overlap:    c.overlap,
+			LastRun:    testStart.Add(-3 * time.Minute),
+			MaxCatchUp: c.maxCatchUp,
+		})
+
+		// the missed activations are run one after another, up to the current one
+		for j := 0; j < c.runs; j++ {
+			run := rec.next(t)
+			if run.Err != nil {
+				t.Errorf("case %d: run %d failed: %v", i, j, run.Err)
+			}
+
+			if expected := testStart.Add(time.Duration(j-c.runs+1) * time.Minute); !run.Scheduled.Equal(expected) {
+				t.Errorf("case %d: Expected run %d to be scheduled at %v, got %v", i, j, expected, run.Scheduled)
+			}
+		}
+
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatalf("case %d: failed to stop scheduler: %v", i, err)
+		}
+
+		if len(rec.runs) != 0 {
+			t.Errorf("case %d: Expected %d runs, got %d more", i, c.runs, len(rec.runs))
+		}
+	}
+}
+
+func TestScheduler_StopTimeout(t *testing.T) {
+	executor := newFakeExecutor(true)
+	rec := newRunRecorder()
+	s, clock := newTestScheduler(t, executor, Entry{
+		Name:     "stop",
+		Schedule: everyMinute,
+		Request:  testRequest,
+		Handler:  rec.handle,
+	})
+
+	clock.blockUntil(t, 1)
+	clock.Advance(time.Minute)
+	<-executor.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := s.Stop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if run := rec.next(t); run.Err != context.Canceled {
+		t.Errorf("Expected running job to be cancelled, got %+v", run)
+	}
+}
+
+func TestScheduler_Add(t *testing.T) {
+	s := New(newFakeExecutor(false))
+
+	if err := s.Add(Entry{Schedule: everyMinute, Request: testRequest}); err != ErrEmptyName {
+		t.Errorf("Expected ErrEmptyName, got %v", err)
+	}
+
+	if err := s.Add(Entry{Name: "test"}); err == nil {
+		t.Error("Expected entry without schedule to be rejected")
+	}
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start scheduler: %v", err)
+	}
+	defer s.Stop(context.Background())
+
+	if err := s.Add(Entry{Name: "test", Schedule: everyMinute, Request: testRequest}); err != ErrAlreadyStarted {
+		t.Errorf("Expected ErrAlreadyStarted, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package puppetmaster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// apiVersionHeader is the response header the puppet master may report its API version in.
+const apiVersionHeader = "X-Api-Version"
+
+// SupportedAPIVersions lists the API versions this client is able to talk to. Every response reporting another
+// version is rejected with ErrIncompatibleAPIVersion.
+var SupportedAPIVersions = []string{"v1"}
+
+// ServerInfo describes the puppet master the client talks to.
+type ServerInfo struct {
+	// APIVersion is the version reported by the puppet master. It is empty if the version is unknown, as the puppet
+	// master reported none.
+	APIVersion string
+	// Latency is the round trip time of the request.
+	Latency time.Duration
+}
+
+// Compatible returns true if the client supports the API version of the server. An unknown version is not.
+func (s *ServerInfo) Compatible() bool {
+	for _, v := range SupportedAPIVersions {
+		if v == s.APIVersion {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkAPIVersion returns ErrIncompatibleAPIVersion if the response reports an unsupported API version. Responses
+// without version are accepted, as older puppet masters do not report it.
+func checkAPIVersion(res *http.Response) error {
+	info := &ServerInfo{APIVersion: res.Header.Get(apiVersionHeader)}
+	if info.APIVersion == "" || info.Compatible() {
+		return nil
+	}
+
+	return fmt.Errorf("%w: server speaks %v, supported are %v", ErrIncompatibleAPIVersion, info.APIVersion, SupportedAPIVersions)
+}
+
+// ServerInfo checks connectivity and authentication by requesting a single job and reports the API version.
+func (c *Client) ServerInfo(ctx context.Context) (*ServerInfo, error) {
+	jobURL := c.buildURL("/jobs", map[string]string{"page": "1", "per_page": "1"})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jobURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(res.Body)
+
+	info := &ServerInfo{Latency: time.Since(start)}
+
+	switch res.StatusCode {
+	case 200:
+	case 401, 403:
+		return nil, ErrUnauthorized
+	default:
+		return nil, unexpectedResponse(res)
+	}
+
+	info.APIVersion = res.Header.Get(apiVersionHeader)
+
+	return info, nil
+}
+
+// Ping returns nil if the puppet master is reachable, accepts the API token and does not report an unsupported API
+// version.
+func (c *Client) Ping(ctx context.Context) error {
+	info, err := c.ServerInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	if info.APIVersion != "" && !info.Compatible() {
+		return fmt.Errorf("%w: server speaks %v, supported are %v", ErrIncompatibleAPIVersion, info.APIVersion, SupportedAPIVersions)
+	}
+
+	return nil
+}
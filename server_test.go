@@ -0,0 +1,93 @@
+package puppetmaster
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestClient_ServerInfo(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("per_page") != "1" {
+			t.Errorf("Expected a single job to be requested, got %v", req.URL.RawQuery)
+		}
+
+		rw.Header().Set(apiVersionHeader, "v1")
+		_, _ = rw.Write([]byte(`{"data":[]}`))
+	}))
+
+	info, err := c.client.ServerInfo(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get server info: %v", err)
+	}
+
+	if info.APIVersion != "v1" || !info.Compatible() {
+		t.Errorf("Expected compatible version v1, got %+v", info)
+	}
+
+	if err := c.client.Ping(context.Background()); err != nil {
+		t.Errorf("Expected ping to succeed, got %v", err)
+	}
+}
+
+func TestClient_ServerInfoUnknownVersion(t *testing.T) {
+	c := newTestClient(t, dumbHandler(200, nil))
+	c.client.baseURL.Path = "/api/v1/teams/my-team"
+
+	info, err := c.client.ServerInfo(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get server info: %v", err)
+	}
+
+	// the base URL says nothing about the server
+	if info.APIVersion != "" || info.Compatible() {
+		t.Errorf("Expected unknown version, got %+v", info)
+	}
+}
+
+func TestClient_IncompatibleAPIVersion(t *testing.T) {
+	cases := []struct {
+		version string
+		err     error
+	}{
+		{version: "", err: nil},
+		{version: "v1", err: nil},
+		{version: "v2", err: ErrIncompatibleAPIVersion},
+	}
+
+	for i, c := range cases {
+		tc := newTestClient(t, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if c.version != "" {
+				rw.Header().Set(apiVersionHeader, c.version)
+			}
+			_, _ = rw.Write(readTestData(t, "get-job-response.json"))
+		}))
+
+		if _, err := tc.client.GetJob("uuid"); !errors.Is(err, c.err) {
+			t.Errorf("case %d: Expected %v, got %v", i, c.err, err)
+		}
+	}
+}
+
+func TestClient_Ping(t *testing.T) {
+	cases := []struct {
+		handler http.Handler
+		err     error
+	}{
+		{handler: dumbHandler(401, nil), err: ErrUnauthorized},
+		{
+			handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set(apiVersionHeader, "v2")
+			}),
+			err: ErrIncompatibleAPIVersion,
+		},
+	}
+
+	for i, c := range cases {
+		tc := newTestClient(t, c.handler)
+		if err := tc.client.Ping(context.Background()); !errors.Is(err, c.err) {
+			t.Errorf("case %d: Expected %v, got %v", i, c.err, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// maxBarWidth is the width of the longest bar of the per-day histogram.
+const maxBarWidth = 40
+
+// WriteJSON writes the report to w as indented JSON.
+func WriteJSON(w io.Writer, r *Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r)
+}
+
+// WriteText writes the report to w as human readable text.
+func WriteText(w io.Writer, r *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "jobs\t%d\n", r.Total)
+
+	statuses := make([]string, 0, len(r.ByStatus))
+	for status := range r.ByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	for _, status := range statuses {
+		fmt.Fprintf(tw, "  %s\t%d\n", status, r.ByStatus[status])
+	}
+
+	fmt.Fprintf(tw, "succeeded\t%d\t%.1f%%\n", r.Succeeded, r.SuccessRate*100)
+	fmt.Fprintf(tw, "failed\t%d\t%.1f%%\n", r.Failed, r.FailureRate*100)
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "\tcount\tmin\tmean\tp50\tp90\tp95\tp99\tmax")
+	for _, d := range []struct {
+		name string
+		dist Distribution
+	}{
+		{"duration", r.Duration},
+		{"queue wait (ms)", r.QueueWait},
+	} {
+		fmt.Fprintf(tw, "%s\t%d\t%.0f\t%.0f\t%.0f\t%.0f\t%.0f\t%.0f\t%.0f\n", d.name, d.dist.Count,
+			d.dist.Min, d.dist.Mean, d.dist.P50, d.dist.P90, d.dist.P95, d.dist.P99, d.dist.Max)
+	}
+
+	if len(r.TopErrors) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "top errors")
+		for _, e := range r.TopErrors {
+			fmt.Fprintf(tw, "  %d\t%s\n", e.Count, strings.ReplaceAll(e.Message, "\n", " "))
+		}
+	}
+
+	if len(r.Days) > 0 {
+		max := 0
+		for _, d := range r.Days {
+			if d.Total > max {
+				max = d.Total
+			}
+		}
+
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "day\tjobs\tfailed\t")
+		for _, d := range r.Days {
+			bar := strings.Repeat("#", (d.Total*maxBarWidth+max-1)/max)
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", d.Date, d.Total, d.Failed, bar)
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,243 @@
+// Package stats aggregates puppet-master jobs into a report of counts, success rates, durations, queue wait times,
+// the most common errors and per-day histograms.
+package stats
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+// dayFormat is the format of DayStats.Date.
+const dayFormat = "2006-01-02"
+
+// Iterator yields jobs one at a time. *puppetmaster.JobIterator implements it.
+type Iterator interface {
+	Next() bool
+	Job() *puppetmaster.Job
+	Err() error
+}
+
+var _ Iterator = (*puppetmaster.JobIterator)(nil)
+
+// Report is the aggregate view over a set of jobs.
+type Report struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+	// Succeeded and Failed count done jobs without and with an error, the rates are relative to all done jobs.
+	Succeeded   int     `json:"succeeded"`
+	Failed      int     `json:"failed"`
+	SuccessRate float64 `json:"success_rate"`
+	FailureRate float64 `json:"failure_rate"`
+	// Duration summarizes Job.Duration of all done jobs, in the unit reported by the puppet master.
+	Duration Distribution `json:"duration"`
+	// QueueWait summarizes the time between creating and starting the jobs, in milliseconds.
+	QueueWait Distribution `json:"queue_wait_ms"`
+	// TopErrors lists the most common error messages, most common first.
+	TopErrors []ErrorCount `json:"top_errors"`
+	// Days counts the jobs per day they were created on, oldest first.
+	Days []DayStats `json:"days"`
+}
+
+// Distribution summarizes a set of values.
+type Distribution struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+// ErrorCount is the number of jobs that failed with the same error message.
+type ErrorCount struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// DayStats counts the jobs created on a single day.
+type DayStats struct {
+	Date      string `json:"date"`
+	Total     int    `json:"total"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+}
+
+// Collector aggregates jobs one at a time. Only durations and queue waits are kept per job, for the percentiles.
+type Collector struct {
+	topErrors int
+	location  *time.Location
+
+	total     int
+	byStatus  map[string]int
+	succeeded int
+	failed    int
+	durations []float64
+	waits     []float64
+	errors    map[string]int
+	days      map[string]*DayStats
+}
+
+// NewCollector returns an empty Collector listing the 10 most common errors and grouping days in UTC.
+func NewCollector() *Collector {
+	return &Collector{
+		topErrors: 10,
+		location:  time.UTC,
+		byStatus:  map[string]int{},
+		errors:    map[string]int{},
+		days:      map[string]*DayStats{},
+	}
+}
+
+// SetTopErrors sets how many of the most common error messages are reported. Negative values are treated like 0.
+func (c *Collector) SetTopErrors(n int) {
+	if n < 0 {
+		n = 0
+	}
+
+	c.topErrors = n
+}
+
+// SetLocation sets the time zone the days of the histogram are based on.
+func (c *Collector) SetLocation(location *time.Location) {
+	c.location = location
+}
+
+// Add adds a job to the statistics.
+func (c *Collector) Add(job *puppetmaster.Job) {
+	c.total++
+	c.byStatus[job.Status]++
+
+	day := job.CreatedAt.In(c.location).Format(dayFormat)
+	if c.days[day] == nil {
+		c.days[day] = &DayStats{Date: day}
+	}
+	c.days[day].Total++
+
+	if job.StartedAt != nil {
+		c.waits = append(c.waits, float64(job.StartedAt.Sub(job.CreatedAt))/float64(time.Millisecond))
+	}
+
+	if job.Status != puppetmaster.StatusDone {
+		return
+	}
+
+	c.durations = append(c.durations, float64(job.Duration))
+
+	if job.Error == "" {
+		c.succeeded++
+		c.days[day].Succeeded++
+		return
+	}
+
+	c.failed++
+	c.days[day].Failed++
+	c.errors[job.Error]++
+}
+
+// Report returns the statistics of all jobs added so far.
+func (c *Collector) Report() *Report {
+	r := &Report{
+		Total:     c.total,
+		ByStatus:  map[string]int{},
+		Succeeded: c.succeeded,
+		Failed:    c.failed,
+		Duration:  distribution(c.durations),
+		QueueWait: distribution(c.waits),
+		TopErrors: []ErrorCount{},
+		Days:      []DayStats{},
+	}
+
+	for status, n := range c.byStatus {
+		r.ByStatus[status] = n
+	}
+
+	if done := c.succeeded + c.failed; done > 0 {
+		r.SuccessRate = float64(c.succeeded) / float64(done)
+		r.FailureRate = float64(c.failed) / float64(done)
+	}
+
+	for message, n := range c.errors {
+		r.TopErrors = append(r.TopErrors, ErrorCount{Message: message, Count: n})
+	}
+	sort.Slice(r.TopErrors, func(i, j int) bool {
+		if r.TopErrors[i].Count != r.TopErrors[j].Count {
+			return r.TopErrors[i].Count > r.TopErrors[j].Count
+		}
+		return r.TopErrors[i].Message < r.TopErrors[j].Message
+	})
+	if len(r.TopErrors) > c.topErrors {
+		r.TopErrors = r.TopErrors[:c.topErrors]
+	}
+
+	for _, day := range c.days {
+		r.Days = append(r.Days, *day)
+	}
+	sort.Slice(r.Days, func(i, j int) bool {
+		return r.Days[i].Date < r.Days[j].Date
+	})
+
+	return r
+}
+
+// FromJobs returns the statistics of the given jobs, e.g. a page of the list API.
+func FromJobs(jobs []puppetmaster.Job) *Report {
+	c := NewCollector()
+	for i := range jobs {
+		c.Add(&jobs[i])
+	}
+
+	return c.Report()
+}
+
+// FromIterator returns the statistics of all jobs of the iterator, e.g. puppetmaster.Client.IterateJobs().
+func FromIterator(it Iterator) (*Report, error) {
+	c := NewCollector()
+	for it.Next() {
+		c.Add(it.Job())
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.Report(), nil
+}
+
+// distribution summarizes values, using the nearest-rank method for percentiles.
+func distribution(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+		return sorted[rank-1]
+	}
+
+	return Distribution{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+		Mean:  sum / float64(len(sorted)),
+		P50:   percentile(50),
+		P90:   percentile(90),
+		P95:   percentile(95),
+		P99:   percentile(99),
+	}
+}
@@ -0,0 +1,228 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+var day = time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func testJob(status, errorMessage string, created time.Time, wait time.Duration, duration int) puppetmaster.Job {
+	job := puppetmaster.Job{Status: status, Error: errorMessage, CreatedAt: created, Duration: duration}
+	if wait > 0 {
+		started := created.Add(wait)
+		job.StartedAt = &started
+	}
+
+	return job
+}
+
+func testJobs() []puppetmaster.Job {
+	return []puppetmaster.Job{
+		testJob(puppetmaster.StatusDone, "", day, time.Second, 100),
+		testJob(puppetmaster.StatusDone, "", day, 2*time.Second, 200),
+		testJob(puppetmaster.StatusDone, "timeout", day, 3*time.Second, 300),
+		testJob(puppetmaster.StatusDone, "timeout", day.Add(24*time.Hour), time.Second, 400),
+		testJob(puppetmaster.StatusDone, "navigation failed", day.Add(24*time.Hour), time.Second, 500),
+		testJob(puppetmaster.StatusQueued, "", day.Add(48*time.Hour), 0, 0),
+		testJob(puppetmaster.StatusCancelled, "", day.Add(48*time.Hour), 0, 0),
+	}
+}
+
+func TestFromJobs(t *testing.T) {
+	r := FromJobs(testJobs())
+
+	if r.Total != 7 || r.Succeeded != 2 || r.Failed != 3 {
+		t.Errorf("Expected 7 jobs, 2 succeeded and 3 failed, got %d, %d and %d", r.Total, r.Succeeded, r.Failed)
+	}
+
+	expByStatus := map[string]int{
+		puppetmaster.StatusDone:      5,
+		puppetmaster.StatusQueued:    1,
+		puppetmaster.StatusCancelled: 1,
+	}
+	if !reflect.DeepEqual(r.ByStatus, expByStatus) {
+		t.Errorf("Expected by status %v, got %v", expByStatus, r.ByStatus)
+	}
+
+	if r.SuccessRate != 0.4 || r.FailureRate != 0.6 {
+		t.Errorf("Expected success rate 0.4 and failure rate 0.6, got %v and %v", r.SuccessRate, r.FailureRate)
+	}
+
+	expDuration := Distribution{Count: 5, Min: 100, Max: 500, Mean: 300, P50: 300, P90: 500, P95: 500, P99: 500}
+	if r.Duration != expDuration {
+		t.Errorf("Expected duration %+v, got %+v", expDuration, r.Duration)
+	}
+
+	if r.QueueWait.Count != 5 || r.QueueWait.Min != 1000 || r.QueueWait.Max != 3000 || r.QueueWait.Mean != 1600 {
+		t.Errorf("Expected queue wait of 5 jobs between 1000ms and 3000ms, got %+v", r.QueueWait)
+	}
+
+	expErrors := []ErrorCount{{Message: "timeout", Count: 2}, {Message: "navigation failed", Count: 1}}
+	if !reflect.DeepEqual(r.TopErrors, expErrors) {
+		t.Errorf("Expected top errors %+v, got %+v", expErrors, r.TopErrors)
+	}
+
+	expDays := []DayStats{
+		{Date: "2026-03-01", Total: 3, Succeeded: 2, Failed: 1},
+		{Date: "2026-03-02", Total: 2, Succeeded: 0, Failed: 2},
+		{Date: "2026-03-03", Total: 2, Succeeded: 0, Failed: 0},
+	}
+	if !reflect.DeepEqual(r.Days, expDays) {
+		t.Errorf("Expected days %+v, got %+v", expDays, r.Days)
+	}
+}
+
+func TestFromJobs_Empty(t *testing.T) {
+	r := FromJobs(nil)
+
+	if r.Total != 0 || r.SuccessRate != 0 || r.Duration.Count != 0 || len(r.TopErrors) != 0 || len(r.Days) != 0 {
+		t.Errorf("Expected empty report, got %+v", r)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	jobs := testJobs()
+
+	cases := []struct {
+		topErrors int
+		location  *time.Location
+		expErrors int
+		expDays   []string
+	}{
+		{10, time.UTC, 2, []string{"2026-03-01", "2026-03-02", "2026-03-03"}},
+		{1, time.UTC, 1, []string{"2026-03-01", "2026-03-02", "2026-03-03"}},
+		{-1, time.UTC, 0, []string{"2026-03-01", "2026-03-02", "2026-03-03"}},
+		{10, time.FixedZone("far east", 16*60*60), 2, []string{"2026-03-02", "2026-03-03", "2026-03-04"}},
+	}
+
+	for i, c := range cases {
+		collector := NewCollector()
+		collector.SetTopErrors(c.topErrors)
+		collector.SetLocation(c.location)
+		for j := range jobs {
+			collector.Add(&jobs[j])
+		}
+		r := collector.Report()
+
+		if len(r.TopErrors) != c.expErrors {
+			t.Errorf("case %d: Expected %d top errors, got %+v", i, c.expErrors, r.TopErrors)
+		}
+
+		var days []string
+		for _, d := range r.Days {
+			days = append(days, d.Date)
+		}
+		if !reflect.DeepEqual(days, c.expDays) {
+			t.Errorf("case %d: Expected days %v, got %v", i, c.expDays, days)
+		}
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	hundred := make([]float64, 100)
+	for i := range hundred {
+		hundred[i] = float64(100 - i)
+	}
+
+	cases := []struct {
+		values []float64
+		exp    Distribution
+	}{
+		{nil, Distribution{}},
+		{[]float64{7}, Distribution{Count: 1, Min: 7, Max: 7, Mean: 7, P50: 7, P90: 7, P95: 7, P99: 7}},
+		{hundred, Distribution{Count: 100, Min: 1, Max: 100, Mean: 50.5, P50: 50, P90: 90, P95: 95, P99: 99}},
+	}
+
+	for i, c := range cases {
+		if d := distribution(c.values); d != c.exp {
+			t.Errorf("case %d: Expected %+v, got %+v", i, c.exp, d)
+		}
+	}
+
+	if hundred[0] != 100 {
+		t.Error("Expected values not to be sorted in place")
+	}
+}
+
+type sliceIterator struct {
+	jobs  []puppetmaster.Job
+	index int
+	err   error
+}
+
+func (s *sliceIterator) Next() bool {
+	s.index++
+	return s.index < len(s.jobs)
+}
+
+func (s *sliceIterator) Job() *puppetmaster.Job {
+	return &s.jobs[s.index]
+}
+
+func (s *sliceIterator) Err() error {
+	return s.err
+}
+
+func TestFromIterator(t *testing.T) {
+	r, err := FromIterator(&sliceIterator{jobs: testJobs(), index: -1})
+	if err != nil {
+		t.Fatalf("failed to collect stats: %v", err)
+	}
+
+	if !reflect.DeepEqual(r, FromJobs(testJobs())) {
+		t.Errorf("Expected same report as from the jobs, got %+v", r)
+	}
+
+	errFailed := errors.New("failed to list jobs")
+	if _, err := FromIterator(&sliceIterator{index: -1, err: errFailed}); err != errFailed {
+		t.Errorf("Expected iterator error, got %v", err)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := WriteText(out, FromJobs(testJobs())); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+
+	for _, exp := range []string{
+		"jobs",
+		"cancelled",
+		"succeeded    2  40.0%",
+		"60.0%",
+		"queue wait (ms)",
+		"2  timeout",
+		"2026-03-01",
+		strings.Repeat("#", maxBarWidth),
+	} {
+		if !strings.Contains(out.String(), exp) {
+			t.Errorf("Expected report to contain %q, got:\n%s", exp, out)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	exp := FromJobs(testJobs())
+
+	out := &bytes.Buffer{}
+	if err := WriteJSON(out, exp); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+
+	r := &Report{}
+	if err := json.Unmarshal(out.Bytes(), r); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+
+	if !reflect.DeepEqual(r, exp) {
+		t.Errorf("Expected %+v, got %+v", exp, r)
+	}
+}
@@ -0,0 +1,207 @@
+package puppetmaster
+
+import (
+	"context"
+	"io"
+	"log"
+	"time"
+)
+
+// SyncOptions configures a single synchronous execution. All fields are optional.
+type SyncOptions struct {
+	// OnLog is called for every log line as soon as it shows up on the job, in the order they were written.
+	OnLog func(Log)
+
+	// OnStatusChange is called for every transition of the job's lifecycle, see JobEvent.
+	OnStatusChange func(StatusChange)
+
+	// BypassCache executes the job even if the cache set by Client.SetCache() holds a result for it. The fresh
+	// result is still written to the cache.
+	BypassCache bool
+}
+
+// Executor executes a job until it is done. *Client implements it, the scheduler, pipeline and worker packages take
+// it to submit their jobs.
+type Executor interface {
+	ExecuteSyncWithOptions(ctx context.Context, jobRequest *JobRequest, opts *SyncOptions) (*Job, error)
+}
+
+var _ Executor = (*Client)(nil)
+
+// CompletionNotifier delivers jobs the puppet master reported as done, e.g. through webhooks.
+type CompletionNotifier interface {
+	// Notify returns a channel that receives the job with the given UUID once it is done. The returned func
+	// releases the subscription and is called as soon as the caller stopped waiting.
+	Notify(uuid string) (<-chan *Job, func())
+}
+
+// SetCompletionNotifier lets ExecuteSync and StreamLogs wait for the notifier instead of polling in the interval set
+// by Client.SetSyncSleepMs(). The job is still polled every fallbackInterval in case a notification gets lost; with
+// a fallbackInterval <= 0 the interval set by Client.SetSyncSleepMs() is used.
+func (c *Client) SetCompletionNotifier(notifier CompletionNotifier, fallbackInterval time.Duration) {
+	c.notifier = notifier
+	c.notifierFallback = fallbackInterval
+}
+
+// ExecuteSyncWithOptions works like ExecuteSync, but reports progress through the callbacks given in opts. When ctx
+// is done before the job, the job is cancelled on the puppet master. A job cancelled by someone else is returned with
+// StatusCancelled.
+func (c *Client) ExecuteSyncWithOptions(ctx context.Context, jobRequest *JobRequest, opts *SyncOptions) (*Job, error) {
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+
+	// the cache is best-effort, failures are treated like a miss
+	if c.cache != nil && !opts.BypassCache {
+		job, err := c.cache.Get(jobRequest.Hash())
+		if err != nil && c.debug {
+			log.Printf("failed to read job from cache: %v", err)
+		}
+
+		if job != nil {
+			return job, nil
+		}
+	}
+
+	job, err := c.CreateJobContext(ctx, jobRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	c.recordInJournal(jobRequest, job)
+
+	t := newJobTracker(opts)
+	t.observe(job)
+
+	uuid := job.UUID
+	if job, err = c.waitForJob(ctx, uuid, t); err != nil {
+		if ctx.Err() != nil {
+			c.cancelAbandonedJob(uuid)
+		}
+
+		return nil, err
+	}
+
+	c.completeInJournal(job.UUID)
+
+	if c.cache != nil && job.Status == StatusDone && job.Error == "" {
+		if err := c.cache.Set(jobRequest.Hash(), job, c.cacheTTL); err != nil && c.debug {
+			log.Printf("failed to write job to cache: %v", err)
+		}
+	}
+
+	return job, nil
+}
+
+// StreamLogs polls the given job and emits every new log line until the job is done. The puppet-master API offers
+// no streaming endpoint, so new lines show up with the interval set by Client.SetSyncSleepMs(). Both channels are
+// closed once the job is done, ctx is cancelled or fetching the job failed, in which case the error is sent first.
+func (c *Client) StreamLogs(ctx context.Context, uuid string) (<-chan Log, <-chan error) {
+	logs := make(chan Log)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(logs)
+
+		_, err := c.waitForJob(ctx, uuid, newJobTracker(&SyncOptions{
+			OnLog: func(l Log) {
+				select {
+				case logs <- l:
+				case <-ctx.Done():
+				}
+			},
+		}))
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return logs, errs
+}
+
+// waitForJob polls the job until it is done, passing every snapshot to the tracker.
+func (c *Client) waitForJob(ctx context.Context, uuid string, t *jobTracker) (*Job, error) {
+	interval := time.Duration(c.syncSleepMs) * time.Millisecond
+
+	var notified <-chan *Job
+	if c.notifier != nil {
+		var release func()
+		notified, release = c.notifier.Notify(uuid)
+		defer release()
+
+		if c.notifierFallback > 0 {
+			interval = c.notifierFallback
+		}
+	}
+
+	for {
+		job, err := c.GetJobContext(ctx, uuid)
+		if err != nil && err != io.EOF {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			return nil, err
+		}
+
+		if err == nil {
+			t.observe(job)
+
+			if job.finished() {
+				return job, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case job := <-notified:
+			t.observe(job)
+			return job, nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// jobTracker remembers what was already reported about a job and fires the callbacks of SyncOptions for
+// everything that changed since.
+type jobTracker struct {
+	opts     *SyncOptions
+	previous *Job
+	seenLogs int
+}
+
+func newJobTracker(opts *SyncOptions) *jobTracker {
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+
+	return &jobTracker{opts: opts}
+}
+
+func (t *jobTracker) observe(job *Job) {
+	// logs are append-only, so everything behind the last seen index is new
+	if len(job.Logs) > t.seenLogs {
+		if t.opts.OnLog != nil {
+			for _, l := range job.Logs[t.seenLogs:] {
+				t.opts.OnLog(l)
+			}
+		}
+		t.seenLogs = len(job.Logs)
+	}
+
+	previous := t.previous
+	if previous != nil && previous.Equal(job) {
+		return
+	}
+	t.previous = job
+
+	if t.opts.OnStatusChange == nil {
+		return
+	}
+
+	for _, event := range jobEvents(previous, job) {
+		t.opts.OnStatusChange(StatusChange{Event: event, Previous: previous, Current: job})
+	}
+}
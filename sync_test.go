@@ -0,0 +1,178 @@
+package puppetmaster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sequenceHandler answers job creation with the first job and every following GET with the next job of the
+// sequence, repeating the last one once the sequence is exhausted.
+func sequenceHandler(t *testing.T, jobs ...Job) http.Handler {
+	var mu sync.Mutex
+	i := 0
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		job := jobs[i]
+		if req.Method == http.MethodGet && i < len(jobs)-1 {
+			i++
+			job = jobs[i]
+		}
+		mu.Unlock()
+
+		code := http.StatusOK
+		if req.Method == http.MethodPost {
+			code = http.StatusCreated
+		}
+
+		rw.WriteHeader(code)
+		if err := json.NewEncoder(rw).Encode(&JobResponse{Data: job}); err != nil {
+			t.Errorf("failed to encode job: %v", err)
+		}
+	})
+}
+
+func testJobSequence() []Job {
+	l1 := Log{Level: LevelInfo, Message: "first"}
+	l2 := Log{Level: LevelInfo, Message: "second"}
+	l3 := Log{Level: LevelInfo, Message: "third"}
+
+	return []Job{
+		{UUID: "uuid", Status: StatusCreated},
+		{UUID: "uuid", Status: StatusQueued, Logs: []Log{l1}},
+		{UUID: "uuid", Status: StatusQueued, Logs: []Log{l1}},
+		{UUID: "uuid", Status: StatusQueued, Logs: []Log{l1, l2}},
+		{UUID: "uuid", Status: StatusDone, Logs: []Log{l1, l2, l3}},
+	}
+}
+
+func TestClient_ExecuteSyncWithOptions(t *testing.T) {
+	c := newTestClient(t, sequenceHandler(t, testJobSequence()...))
+	c.client.SetSyncSleepMs(1)
+
+	var messages []string
+	job, err := c.client.ExecuteSyncWithOptions(context.Background(), &JobRequest{Code: "test"}, &SyncOptions{
+		OnLog: func(l Log) {
+			messages = append(messages, l.Message)
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to execute job: %v", err)
+	}
+
+	if job.Status != StatusDone {
+		t.Errorf("Expected job to be done, got status %v", job.Status)
+	}
+
+	if len(messages) != 3 || messages[0] != "first" || messages[1] != "second" || messages[2] != "third" {
+		t.Errorf("Expected each log to be reported once in order, got %v", messages)
+	}
+}
+
+func TestClient_ExecuteSyncWithOptionsCancelled(t *testing.T) {
+	c := newTestClient(t, sequenceHandler(t, Job{UUID: "uuid", Status: StatusQueued}))
+	c.client.SetSyncSleepMs(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := c.client.ExecuteSyncWithOptions(ctx, &JobRequest{Code: "test"}, nil)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestClient_StreamLogs(t *testing.T) {
+	c := newTestClient(t, sequenceHandler(t, testJobSequence()...))
+	c.client.SetSyncSleepMs(1)
+
+	logs, errs := c.client.StreamLogs(context.Background(), "uuid")
+
+	var messages []string
+	for l := range logs {
+		messages = append(messages, l.Message)
+	}
+
+	if err := <-errs; err != nil {
+		t.Fatalf("failed to stream logs: %v", err)
+	}
+
+	if len(messages) != 3 {
+		t.Errorf("Expected 3 streamed logs, got %v", messages)
+	}
+}
+
+func TestClient_StreamLogsNotFound(t *testing.T) {
+	c := newTestClient(t, dumbHandler(404, nil))
+
+	logs, errs := c.client.StreamLogs(context.Background(), "uuid")
+	for range logs {
+		t.Error("Expected no logs to be streamed")
+	}
+
+	if err := <-errs; err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+type testNotifier chan *Job
+
+func (n testNotifier) Notify(uuid string) (<-chan *Job, func()) {
+	return n, func() {}
+}
+
+func TestClient_ExecuteSyncCompletionNotifier(t *testing.T) {
+	c := newTestClient(t, sequenceHandler(t, Job{UUID: "uuid", Status: StatusQueued}))
+	c.client.SetSyncSleepMs(1)
+
+	notifier := make(testNotifier, 1)
+	c.client.SetCompletionNotifier(notifier, time.Hour)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		notifier <- &Job{UUID: "uuid", Status: StatusDone}
+	}()
+
+	job, err := c.client.ExecuteSyncWithOptions(context.Background(), &JobRequest{Code: "test"}, nil)
+	if err != nil {
+		t.Fatalf("failed to execute job: %v", err)
+	}
+
+	if job.Status != StatusDone {
+		t.Errorf("Expected notified job to be done, got status %v", job.Status)
+	}
+}
+
+func TestClient_ExecuteSyncCompletionNotifierNoFallback(t *testing.T) {
+	var gets int32
+	handler := sequenceHandler(t, Job{UUID: "uuid", Status: StatusQueued})
+	c := newTestClient(t, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet {
+			atomic.AddInt32(&gets, 1)
+		}
+		handler.ServeHTTP(rw, req)
+	}))
+	c.client.SetSyncSleepMs(20)
+
+	// without a fallback interval, the job is polled in the sync interval instead of continuously
+	notifier := make(testNotifier, 1)
+	c.client.SetCompletionNotifier(notifier, 0)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		notifier <- &Job{UUID: "uuid", Status: StatusDone}
+	}()
+
+	if _, err := c.client.ExecuteSyncWithOptions(context.Background(), &JobRequest{Code: "test"}, nil); err != nil {
+		t.Fatalf("failed to execute job: %v", err)
+	}
+
+	if n := atomic.LoadInt32(&gets); n > 10 {
+		t.Errorf("Expected job to be polled in the sync interval, got %d requests", n)
+	}
+}
@@ -0,0 +1,196 @@
+package puppetmaster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies the API token sent with every request.
+type TokenSource interface {
+	// Token returns the token to use for the next request.
+	Token(ctx context.Context) (string, error)
+}
+
+// InvalidatingTokenSource is a TokenSource that can drop its current token. The client invalidates the token when
+// the puppet master rejects it and retries the request once with the next one.
+type InvalidatingTokenSource interface {
+	TokenSource
+	Invalidate()
+}
+
+type tokenOverrideKey struct{}
+
+// WithToken returns a context overriding the token of the client's TokenSource for all requests sent with it, e.g.
+// to send requests on behalf of different tenants with a single client.
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenOverrideKey{}, token)
+}
+
+func tokenOverride(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(tokenOverrideKey{}).(string)
+	return token, ok
+}
+
+// StaticTokenSource always returns the same token, which can be rotated at runtime by calling SetToken().
+type StaticTokenSource struct {
+	mu    sync.RWMutex
+	token string
+}
+
+// NewStaticTokenSource returns a new StaticTokenSource returning the given token.
+func NewStaticTokenSource(token string) *StaticTokenSource {
+	return &StaticTokenSource{token: token}
+}
+
+// SetToken replaces the token used for subsequent requests.
+func (s *StaticTokenSource) SetToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = token
+}
+
+// Token implements TokenSource.
+func (s *StaticTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.token, nil
+}
+
+// EnvTokenSource reads the token from the environment variable with the given name on every request.
+type EnvTokenSource string
+
+// Token implements TokenSource.
+func (e EnvTokenSource) Token(ctx context.Context) (string, error) {
+	token := strings.TrimSpace(os.Getenv(string(e)))
+	if token == "" {
+		return "", fmt.Errorf("environment variable %v: %v", string(e), ErrEmptyAPIToken)
+	}
+
+	return token, nil
+}
+
+// FileTokenSource reads the token from the file with the given path on every request, so it picks up tokens rotated
+// by e.g. a secret mount.
+type FileTokenSource string
+
+// Token implements TokenSource.
+func (f FileTokenSource) Token(ctx context.Context) (string, error) {
+	b, err := os.ReadFile(string(f))
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("file %v: %v", string(f), ErrEmptyAPIToken)
+	}
+
+	return token, nil
+}
+
+// ClientCredentialsTokenSource obtains tokens through the OAuth2 client credentials grant and caches them until
+// shortly before they expire.
+type ClientCredentialsTokenSource struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// expiryDelta is subtracted from the lifetime of tokens, so they are not used right before they expire.
+const expiryDelta = 10 * time.Second
+
+// NewClientCredentialsTokenSource returns a new ClientCredentialsTokenSource requesting tokens from tokenURL.
+func NewClientCredentialsTokenSource(tokenURL, clientID, clientSecret string, scopes ...string) *ClientCredentialsTokenSource {
+	return &ClientCredentialsTokenSource{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+	}
+}
+
+// Token implements TokenSource.
+func (s *ClientCredentialsTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && (s.expiresAt.IsZero() || time.Now().Before(s.expiresAt)) {
+		return s.token, nil
+	}
+
+	token, expiresIn, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token
+	s.expiresAt = time.Time{}
+	if expiresIn > 0 {
+		s.expiresAt = time.Now().Add(time.Duration(expiresIn)*time.Second - expiryDelta)
+	}
+
+	return s.token, nil
+}
+
+// Invalidate implements InvalidatingTokenSource.
+func (s *ClientCredentialsTokenSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = ""
+}
+
+func (s *ClientCredentialsTokenSource) fetch(ctx context.Context) (string, int64, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.scopes) > 0 {
+		form.Set("scope", strings.Join(s.scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer closeBody(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return "", 0, unexpectedResponse(res)
+	}
+
+	body := &struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxTokenResponseSize)).Decode(body); err != nil {
+		return "", 0, fmt.Errorf("failed to decode token response: %v", err)
+	}
+
+	if body.AccessToken == "" {
+		return "", 0, fmt.Errorf("token response: %v", ErrEmptyAPIToken)
+	}
+
+	return body.AccessToken, body.ExpiresIn, nil
+}
@@ -0,0 +1,158 @@
+package puppetmaster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewClientWithTokenSourceNil(t *testing.T) {
+	if _, err := NewClientWithTokenSource("http://localhost", nil); err != ErrNoTokenSource {
+		t.Fatalf("Expected ErrNoTokenSource, got %v", err)
+	}
+}
+
+func TestStaticTokenSource(t *testing.T) {
+	s := NewStaticTokenSource("old")
+	s.SetToken("new")
+
+	if token, _ := s.Token(context.Background()); token != "new" {
+		t.Errorf("Expected rotated token, got %q", token)
+	}
+}
+
+func TestEnvTokenSource(t *testing.T) {
+	t.Setenv("PUPPET_MASTER_TEST_TOKEN", " token\n")
+
+	if token, err := EnvTokenSource("PUPPET_MASTER_TEST_TOKEN").Token(context.Background()); err != nil || token != "token" {
+		t.Errorf("Expected token from env, got %q, %v", token, err)
+	}
+
+	if _, err := EnvTokenSource("PUPPET_MASTER_TEST_MISSING").Token(context.Background()); err == nil {
+		t.Error("Expected missing variable to fail")
+	}
+}
+
+func TestFileTokenSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("token\n"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	if token, err := FileTokenSource(path).Token(context.Background()); err != nil || token != "token" {
+		t.Errorf("Expected token from file, got %q, %v", token, err)
+	}
+}
+
+func TestClientCredentialsTokenSource(t *testing.T) {
+	issued := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		id, secret, _ := req.BasicAuth()
+		if id != "id" || secret != "secret" || req.FormValue("grant_type") != "client_credentials" || req.FormValue("scope") != "jobs" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		issued++
+		if err := json.NewEncoder(rw).Encode(map[string]interface{}{"access_token": strings.Repeat("t", issued), "expires_in": 3600}); err != nil {
+			t.Errorf("failed to encode token: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	s := NewClientCredentialsTokenSource(server.URL, "id", "secret", "jobs")
+	for _, exp := range []string{"t", "t"} {
+		if token, err := s.Token(context.Background()); err != nil || token != exp {
+			t.Fatalf("Expected token %q, got %q, %v", exp, token, err)
+		}
+	}
+
+	s.Invalidate()
+	if token, err := s.Token(context.Background()); err != nil || token != "tt" {
+		t.Fatalf("Expected fresh token after invalidation, got %q, %v", token, err)
+	}
+}
+
+// rotatingTokenSource hands out the next token after every invalidation.
+type rotatingTokenSource struct {
+	tokens      []string
+	invalidated int
+}
+
+func (r *rotatingTokenSource) Token(ctx context.Context) (string, error) {
+	return r.tokens[r.invalidated], nil
+}
+
+func (r *rotatingTokenSource) Invalidate() {
+	r.invalidated++
+}
+
+func tokenCheckingHandler(valid string, body []byte, seen *[]string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		token := strings.TrimPrefix(req.Header.Get(authHeader), "Bearer ")
+		*seen = append(*seen, token)
+
+		if token != valid {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write(body)
+	})
+}
+
+func TestClient_RetryOnUnauthorized(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(tokenCheckingHandler("new", readTestData(t, "create-response.json"), &seen))
+	defer server.Close()
+
+	tokens := &rotatingTokenSource{tokens: []string{"old", "new"}}
+	c, err := NewClientWithTokenSource(server.URL, tokens)
+	if err != nil {
+		t.Fatalf("failed to construct client: %v", err)
+	}
+
+	jobReq := &JobRequest{}
+	readJSONFileInto(t, "create-request.json", jobReq)
+
+	if _, err := c.CreateJob(jobReq); err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+
+	if tokens.invalidated != 1 || len(seen) != 2 {
+		t.Errorf("Expected one retry with a fresh token, got tokens %v", seen)
+	}
+}
+
+func TestClient_WithToken(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(tokenCheckingHandler("tenant", readTestData(t, "create-response.json"), &seen))
+	defer server.Close()
+
+	tokens := &rotatingTokenSource{tokens: []string{"default", "other"}}
+	c, err := NewClientWithTokenSource(server.URL, tokens)
+	if err != nil {
+		t.Fatalf("failed to construct client: %v", err)
+	}
+
+	jobReq := &JobRequest{}
+	readJSONFileInto(t, "create-request.json", jobReq)
+
+	if _, err := c.CreateJobContext(WithToken(context.Background(), "tenant"), jobReq); err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+
+	if _, err := c.CreateJobContext(WithToken(context.Background(), "wrong"), jobReq); err == nil {
+		t.Fatal("Expected wrong token to be rejected")
+	}
+
+	if tokens.invalidated != 0 || strings.Join(seen, ",") != "tenant,wrong" {
+		t.Errorf("Expected overridden tokens without retry, got tokens %v", seen)
+	}
+}
@@ -1,12 +1,34 @@
 package puppetmaster
 
 import (
+	"encoding/json"
 	"time"
 )
 
 // JobPagination holds information about the paginated jobs list.
 type JobPagination struct {
-	Jobs []Job `json:"data"`
+	Jobs  []Job           `json:"data"`
+	Links PaginationLinks `json:"links"`
+	Meta  PaginationMeta  `json:"meta"`
+}
+
+// PaginationLinks holds the URLs of neighbouring pages, empty if there is no such page.
+type PaginationLinks struct {
+	First string `json:"first"`
+	Last  string `json:"last"`
+	Prev  string `json:"prev"`
+	Next  string `json:"next"`
+}
+
+// PaginationMeta describes the position of a page within the paginated list.
+type PaginationMeta struct {
+	CurrentPage uint   `json:"current_page"`
+	LastPage    uint   `json:"last_page"`
+	PerPage     uint   `json:"per_page"`
+	Total       uint   `json:"total"`
+	From        uint   `json:"from"`
+	To          uint   `json:"to"`
+	Path        string `json:"path"`
 }
 
 // JobRequest defines how to create a job.
@@ -14,12 +36,18 @@ type JobRequest struct {
 	Code    string            `json:"code"`
 	Vars    map[string]string `json:"vars"`
 	Modules map[string]string `json:"modules"`
+	// CallbackURL is notified by the puppet master once the job is done, see the webhook package.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// IdempotencyKey makes retries of Client.CreateJob safe. It is sent as header, so the puppet master can
+	// deduplicate requests, and the client looks for a job created by a previous failed attempt with the same key.
+	IdempotencyKey string `json:"-"`
 }
 
 // JobResponse is an api wrapper around a single job.
 type JobResponse struct {
-	Errors map[string][]string `json:"errors"`
-	Data   Job                 `json:"data"`
+	Message string              `json:"message,omitempty"`
+	Errors  map[string][]string `json:"errors"`
+	Data    Job                 `json:"data"`
 }
 
 // Job represents a complete job including status, results and logs.
@@ -36,11 +64,30 @@ type Job struct {
 	StartedAt  *time.Time             `json:"started_at"`
 	FinishedAt *time.Time             `json:"finished_at"`
 	Duration   int                    `json:"duration"`
+	// ErrorPresence tells whether the puppet master sent the error as string, as null or not at all, which Error
+	// alone can not tell apart from an empty one.
+	ErrorPresence FieldPresence `json:"-"`
+	// Extra keeps fields sent by the puppet master that are unknown to this client, so they survive a round trip
+	// through JSON, e.g. in a Cache.
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
+// FieldPresence tells how a field showed up in a JSON object.
+type FieldPresence int
+
+// possible field presences
+const (
+	// FieldPresent is a field with a value. It is the zero value, so jobs built in code are encoded as usual.
+	FieldPresent FieldPresence = iota
+	// FieldNull is a field set to null.
+	FieldNull
+	// FieldMissing is a field that was not sent at all.
+	FieldMissing
+)
+
 // A Log represents a log line yielded by the executor
 type Log struct {
 	Time    time.Time `json:"time"`
-	Level   string    `json:"level"`
+	Level   LogLevel  `json:"level"`
 	Message string    `json:"message"`
 }
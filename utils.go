@@ -2,19 +2,114 @@ package puppetmaster
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"net/http/httputil"
 	"strings"
 )
 
+const (
+	// defaultMaxResponseSize is the default of Client.SetMaxResponseSize().
+	defaultMaxResponseSize = 32 << 20
+	// maxTokenResponseSize limits the response of the token endpoint.
+	maxTokenResponseSize = 1 << 20
+	// maxErrorBodySize is the size of response bodies embedded in errors, longer bodies are truncated.
+	maxErrorBodySize = 1 << 10
+	// maxDrainSize is read from unread bodies before closing them, so the connection can be reused.
+	maxDrainSize = 64 << 10
+)
+
+// ResponseError is thrown when the puppet master answers with a status code the client does not expect.
+type ResponseError struct {
+	StatusCode int
+	Status     string
+	// Body is the response body, truncated to 1 KiB.
+	Body string
+	// Err is set if reading the body failed.
+	Err error
+}
+
+func (e *ResponseError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("failed to read body of failed response (%v): %v", e.Status, e.Err)
+	}
+
+	return fmt.Sprintf("unexpected response %v: %v", e.Status, e.Body)
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
 func unexpectedResponse(res *http.Response) error {
-	b, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read body of failed response (%v): %v", res.Status, err)
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize+1))
+	if err != nil && len(b) == 0 {
+		return &ResponseError{StatusCode: res.StatusCode, Status: res.Status, Err: err}
+	}
+	return &ResponseError{StatusCode: res.StatusCode, Status: res.Status, Body: truncateBody(b)}
+}
+
+// truncateBody renders a response body for an error message, truncated to maxErrorBodySize.
+func truncateBody(b []byte) string {
+	if len(b) <= maxErrorBodySize {
+		return string(b)
 	}
-	return fmt.Errorf("unexpected response %v: %v", res.Status, string(b))
+
+	return strings.ToValidUTF8(string(b[:maxErrorBodySize]), "") + "... (truncated)"
+}
+
+// checkContentType rejects responses that are no JSON, like the HTML error page of a proxy. Responses without content
+// type or labeled as text/plain are accepted, as some servers send JSON that way.
+func checkContentType(res *http.Response) error {
+	contentType := res.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || mediaType == "text/plain") {
+		return nil
+	}
+
+	b, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize+1))
+	return fmt.Errorf("%w %q (%v): %v", ErrUnexpectedContentType, contentType, res.Status, truncateBody(b))
+}
+
+// closeBody drains what is left of a response body, up to maxDrainSize, and closes it.
+func closeBody(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrainSize))
+	_ = body.Close()
+}
+
+// limitedBody fails with ErrResponseTooLarge when the body is longer than limit.
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// check whether the body ends right at the limit
+		var probe [1]byte
+		n, err := b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, b.limit)
+		}
+
+		return 0, err
+	}
+
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+
+	return n, err
 }
 
 func unprocessableEntity(res *http.Response, errs map[string][]string) error {
@@ -23,7 +118,7 @@ func unprocessableEntity(res *http.Response, errs map[string][]string) error {
 		errStrs = append(errStrs, fmt.Sprintf("%s (%v)", field, strings.Join(e, ", ")))
 	}
 
-	return fmt.Errorf("failed to save job. The following fields are invalid: %v", strings.Join(errStrs, ", "))
+	return fmt.Errorf("failed to save job: %w, the following fields are invalid: %v", ErrInvalidJobRequest, strings.Join(errStrs, ", "))
 }
 
 func dumpRequest(req *http.Request) {
@@ -0,0 +1,212 @@
+package puppetmaster
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// contentTypeHandler responds with the given status, content type and body.
+func contentTypeHandler(code int, contentType string, body []byte) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", contentType)
+		rw.WriteHeader(code)
+		_, _ = rw.Write(body)
+	})
+}
+
+func TestClient_SetMaxResponseSize(t *testing.T) {
+	body := readTestData(t, "get-job-response.json")
+
+	cases := []struct {
+		size   int64
+		expErr error
+	}{
+		{size: defaultMaxResponseSize},
+		{size: int64(len(body))},
+		{size: 0},
+		{size: int64(len(bytes.TrimSpace(body))) - 1, expErr: ErrResponseTooLarge},
+		{size: 10, expErr: ErrResponseTooLarge},
+	}
+
+	for i, c := range cases {
+		tc := newTestClient(t, contentTypeHandler(200, "application/json", body))
+		tc.client.SetMaxResponseSize(c.size)
+
+		_, err := tc.client.GetJob("73e3a9b5-81c8-4743-9a7e-e80474c1b6e3")
+		tc.server.Close()
+
+		if !errors.Is(err, c.expErr) {
+			t.Errorf("case %d: Expected error %v, got %v", i, c.expErr, err)
+		}
+	}
+}
+
+func TestClient_ContentType(t *testing.T) {
+	body := readTestData(t, "get-job-response.json")
+
+	cases := []struct {
+		contentType string
+		expErr      error
+	}{
+		{contentType: "application/json"},
+		{contentType: "application/json; charset=utf-8"},
+		{contentType: "application/vnd.api+json"},
+		{contentType: "text/plain; charset=utf-8"},
+		{contentType: "text/html", expErr: ErrUnexpectedContentType},
+		{contentType: "invalid;;", expErr: ErrUnexpectedContentType},
+	}
+
+	for i, c := range cases {
+		tc := newTestClient(t, contentTypeHandler(200, c.contentType, body))
+		_, err := tc.client.GetJob("73e3a9b5-81c8-4743-9a7e-e80474c1b6e3")
+		tc.server.Close()
+
+		if !errors.Is(err, c.expErr) {
+			t.Errorf("case %d: Expected error %v for %q, got %v", i, c.expErr, c.contentType, err)
+		}
+	}
+}
+
+func TestUnexpectedResponse_Truncated(t *testing.T) {
+	page := []byte("<html>" + strings.Repeat("bad gateway ", 10000) + "</html>")
+
+	c := newTestClient(t, contentTypeHandler(http.StatusBadGateway, "text/html", page))
+	defer c.server.Close()
+
+	for _, create := range []bool{false, true} {
+		var err error
+		if create {
+			_, err = c.client.CreateJob(&JobRequest{Code: "test"})
+		} else {
+			_, err = c.client.GetJob("abc")
+		}
+
+		if err == nil {
+			t.Fatal("Expected error for bad gateway")
+		}
+
+		if !strings.Contains(err.Error(), "502") || !strings.HasSuffix(err.Error(), "... (truncated)") {
+			t.Errorf("Expected status and truncated body, got %v", err)
+		}
+
+		if len(err.Error()) > maxErrorBodySize+100 {
+			t.Errorf("Expected error to be at most %d bytes, got %d", maxErrorBodySize+100, len(err.Error()))
+		}
+	}
+}
+
+// closeTracker counts response bodies that were not closed.
+type closeTracker struct {
+	mu   sync.Mutex
+	open int
+}
+
+func (c *closeTracker) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.open++
+	c.mu.Unlock()
+
+	res.Body = &trackedBody{ReadCloser: res.Body, tracker: c}
+
+	return res, nil
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	tracker *closeTracker
+	once    sync.Once
+}
+
+func (b *trackedBody) Close() error {
+	b.once.Do(func() {
+		b.tracker.mu.Lock()
+		b.tracker.open--
+		b.tracker.mu.Unlock()
+	})
+
+	return b.ReadCloser.Close()
+}
+
+func TestClient_ClosesBodies(t *testing.T) {
+	job := readTestData(t, "get-job-response.json")
+	created := readTestData(t, "create-response.json")
+	jobs := readTestData(t, "get-jobs-response.json")
+
+	cases := []struct {
+		handler http.Handler
+		call    func(c *Client) error
+		err     string
+	}{
+		{handler: dumbHandler(200, bytes.NewReader(job)), call: func(c *Client) error {
+			_, err := c.GetJob("abc")
+			return err
+		}},
+		{handler: dumbHandler(404, nil), err: ErrNotFound.Error(), call: func(c *Client) error {
+			_, err := c.GetJob("abc")
+			return err
+		}},
+		{handler: dumbHandler(201, bytes.NewReader(created)), call: func(c *Client) error {
+			_, err := c.CreateJob(&JobRequest{Code: "test"})
+			return err
+		}},
+		{handler: dumbHandler(422, strings.NewReader(`{"errors": {"code": ["invalid"]}}`)), err: "code", call: func(c *Client) error {
+			_, err := c.CreateJob(&JobRequest{Code: "test"})
+			return err
+		}},
+		{handler: dumbHandler(200, bytes.NewReader(jobs)), call: func(c *Client) error {
+			_, err := c.GetJobs(1, 15)
+			return err
+		}},
+		{handler: dumbHandler(500, strings.NewReader("oops")), err: "oops", call: func(c *Client) error {
+			_, err := c.GetJobs(1, 15)
+			return err
+		}},
+		{handler: dumbHandler(204, nil), call: func(c *Client) error {
+			return c.DeleteJob("abc")
+		}},
+		{handler: dumbHandler(500, strings.NewReader("oops")), err: "oops", call: func(c *Client) error {
+			return c.DeleteJob("abc")
+		}},
+	}
+
+	for i, c := range cases {
+		tc := newTestClient(t, c.handler)
+		tracker := &closeTracker{}
+		tc.client.SetHTTPClient(&http.Client{Transport: tracker})
+
+		err := c.call(tc.client)
+		tc.server.Close()
+
+		if c.err == "" && err != nil {
+			t.Errorf("case %d: Expected no error, got %v", i, err)
+		}
+
+		if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("case %d: Expected error containing %q, got %v", i, c.err, err)
+		}
+
+		if tracker.open != 0 {
+			t.Errorf("case %d: Expected all response bodies to be closed, %d are open", i, tracker.open)
+		}
+	}
+}
+
+func TestClient_DeleteJob_UnexpectedResponse(t *testing.T) {
+	c := newTestClient(t, dumbHandler(500, strings.NewReader("database is down")))
+	defer c.server.Close()
+
+	err := c.client.DeleteJob("abc")
+	if err == nil || !strings.Contains(err.Error(), "database is down") {
+		t.Errorf("Expected error with response body, got %v", err)
+	}
+}
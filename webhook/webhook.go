@@ -0,0 +1,194 @@
+// Package webhook receives the job completion callbacks the puppet master sends to JobRequest.CallbackURL.
+//
+// Every callback is signed with a secret shared between the puppet master and the receiver. The signature is sent in
+// the X-Puppet-Master-Signature header as "sha256=" followed by the hex encoded HMAC-SHA256 of the value of the
+// X-Puppet-Master-Timestamp header (unix seconds), a dot and the request body.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+// header names used by the puppet master for signing callbacks
+const (
+	SignatureHeader = "X-Puppet-Master-Signature"
+	TimestampHeader = "X-Puppet-Master-Timestamp"
+)
+
+const signaturePrefix = "sha256="
+
+// maxBodySize limits the size of accepted callbacks.
+const maxBodySize = 10 << 20
+
+var (
+	// ErrEmptySecret is thrown when the secret given to NewHandler() is empty.
+	ErrEmptySecret = errors.New("secret may not be empty")
+
+	// ErrInvalidSignature is thrown when a callback is not signed with the shared secret.
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrExpiredTimestamp is thrown when the timestamp of a callback is outside of the tolerated window.
+	ErrExpiredTimestamp = errors.New("timestamp is outside of the tolerated window")
+)
+
+// Handler is an http.Handler verifying and dispatching job completion callbacks. It also implements
+// puppetmaster.CompletionNotifier, so it can be passed to Client.SetCompletionNotifier().
+type Handler struct {
+	secret    []byte
+	tolerance time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	handlers []func(*puppetmaster.Job)
+	waiters  map[string][]chan *puppetmaster.Job
+}
+
+// NewHandler returns a new Handler verifying callbacks with the given secret.
+func NewHandler(secret string) (*Handler, error) {
+	if secret == "" {
+		return nil, ErrEmptySecret
+	}
+
+	return &Handler{
+		secret:    []byte(secret),
+		tolerance: 5 * time.Minute,
+		now:       time.Now,
+		waiters:   map[string][]chan *puppetmaster.Job{},
+	}, nil
+}
+
+// SetTolerance sets how far the timestamp of a callback may differ from the local time, 5 minutes by default.
+// Callbacks outside of this window are rejected to prevent replays.
+func (h *Handler) SetTolerance(tolerance time.Duration) {
+	h.tolerance = tolerance
+}
+
+// HandleFunc registers a func called with every job received.
+func (h *Handler) HandleFunc(fn func(*puppetmaster.Job)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.handlers = append(h.handlers, fn)
+}
+
+// Notify implements puppetmaster.CompletionNotifier.
+func (h *Handler) Notify(uuid string) (<-chan *puppetmaster.Job, func()) {
+	ch := make(chan *puppetmaster.Job, 1)
+
+	h.mu.Lock()
+	h.waiters[uuid] = append(h.waiters[uuid], ch)
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		waiters := h.waiters[uuid]
+		for i, w := range waiters {
+			if w == ch {
+				waiters = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+
+		if len(waiters) == 0 {
+			delete(h.waiters, uuid)
+		} else {
+			h.waiters[uuid] = waiters
+		}
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize))
+	if err != nil {
+		http.Error(rw, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.verify(req.Header.Get(TimestampHeader), req.Header.Get(SignatureHeader), body); err != nil {
+		http.Error(rw, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	res := &puppetmaster.JobResponse{}
+	if err := json.Unmarshal(body, res); err != nil {
+		http.Error(rw, fmt.Sprintf("failed to decode job: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	h.dispatch(&res.Data)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) verify(timestamp, signature string, body []byte) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrExpiredTimestamp
+	}
+
+	diff := h.now().Sub(time.Unix(ts, 0))
+	if diff > h.tolerance || diff < -h.tolerance {
+		return ErrExpiredTimestamp
+	}
+
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+
+	given, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil || !hmac.Equal(given, sign(h.secret, timestamp, body)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func (h *Handler) dispatch(job *puppetmaster.Job) {
+	h.mu.Lock()
+	handlers := append([]func(*puppetmaster.Job){}, h.handlers...)
+	waiters := h.waiters[job.UUID]
+	delete(h.waiters, job.UUID)
+	h.mu.Unlock()
+
+	for _, w := range waiters {
+		w <- job
+	}
+
+	for _, fn := range handlers {
+		fn(job)
+	}
+}
+
+// Sign returns the signature header value for the given secret, timestamp and body.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(sign([]byte(secret), strconv.FormatInt(timestamp.Unix(), 10), body))
+}
+
+func sign(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+const testSecret = "shared-secret"
+
+var testBody = []byte(`{"data":{"uuid":"73e3a9b5-81c8-4743-9a7e-e80474c1b6e3","status":"done","results":{"ip":"127.0.0.1"}}}`)
+
+func newTestHandler(t *testing.T, now time.Time) *Handler {
+	h, err := NewHandler(testSecret)
+	if err != nil {
+		t.Fatalf("failed to construct handler: %v", err)
+	}
+
+	h.now = func() time.Time { return now }
+
+	return h
+}
+
+func newCallback(secret string, ts time.Time, body []byte) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/callback", bytes.NewReader(body))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(secret, ts, body))
+
+	return req
+}
+
+func TestNewHandlerEmptySecret(t *testing.T) {
+	if _, err := NewHandler(""); err != ErrEmptySecret {
+		t.Fatalf("Expected ErrEmptySecret, got %v", err)
+	}
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		req  *http.Request
+		code int
+	}{
+		{req: newCallback(testSecret, now, testBody), code: http.StatusNoContent},
+		{req: newCallback(testSecret, now.Add(-time.Minute), testBody), code: http.StatusNoContent},
+		{req: newCallback("other-secret", now, testBody), code: http.StatusUnauthorized},
+		{req: newCallback(testSecret, now.Add(-10*time.Minute), testBody), code: http.StatusUnauthorized},
+		{req: newCallback(testSecret, now.Add(10*time.Minute), testBody), code: http.StatusUnauthorized},
+		{req: newCallback(testSecret, now, []byte("no json")), code: http.StatusBadRequest},
+		{req: httptest.NewRequest(http.MethodGet, "/callback", nil), code: http.StatusMethodNotAllowed},
+	}
+
+	for i, c := range cases {
+		rec := httptest.NewRecorder()
+		newTestHandler(t, now).ServeHTTP(rec, c.req)
+
+		if rec.Code != c.code {
+			t.Errorf("case %d: Expected status %d, got %d: %s", i, c.code, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandler_TamperedBody(t *testing.T) {
+	now := time.Now()
+	req := newCallback(testSecret, now, testBody)
+	req.Body = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(append(testBody, ' '))).Body
+
+	rec := httptest.NewRecorder()
+	newTestHandler(t, now).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestHandler_Dispatch(t *testing.T) {
+	now := time.Now()
+	h := newTestHandler(t, now)
+
+	var handled *puppetmaster.Job
+	h.HandleFunc(func(job *puppetmaster.Job) {
+		handled = job
+	})
+
+	notified, release := h.Notify("73e3a9b5-81c8-4743-9a7e-e80474c1b6e3")
+	defer release()
+
+	other, releaseOther := h.Notify("other")
+	defer releaseOther()
+
+	h.ServeHTTP(httptest.NewRecorder(), newCallback(testSecret, now, testBody))
+
+	if handled == nil || handled.Results["ip"] != "127.0.0.1" {
+		t.Fatalf("Expected handler to receive the job, got %+v", handled)
+	}
+
+	select {
+	case job := <-notified:
+		if job.Status != puppetmaster.StatusDone {
+			t.Errorf("Expected notified job to be done, got %v", job.Status)
+		}
+	default:
+		t.Error("Expected waiter to be notified")
+	}
+
+	select {
+	case <-other:
+		t.Error("Expected waiter of other job not to be notified")
+	default:
+	}
+}
@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+// ErrSourceClosed is thrown when requests are pushed to a closed ChannelSource.
+var ErrSourceClosed = errors.New("source is closed")
+
+// ChannelSource is an in-memory Source backed by a channel. Requests acked with an error are delivered again before
+// new ones, they are lost when the process exits though; use a SpoolSource to keep them.
+type ChannelSource struct {
+	requests chan *puppetmaster.JobRequest
+
+	mu     sync.RWMutex
+	closed bool
+
+	// retry has its own lock, mu is held by Push while blocking on a full buffer
+	retryMu sync.Mutex
+	retry   []*puppetmaster.JobRequest
+}
+
+var _ Source = (*ChannelSource)(nil)
+
+// NewChannelSource returns a new ChannelSource buffering up to size requests.
+func NewChannelSource(size int) *ChannelSource {
+	return &ChannelSource{requests: make(chan *puppetmaster.JobRequest, size)}
+}
+
+// Push adds a request, blocking while the buffer is full.
+func (s *ChannelSource) Push(ctx context.Context, jobRequest *puppetmaster.JobRequest) error {
+	// the read lock keeps Close() from closing the channel while sending on it
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return ErrSourceClosed
+	}
+
+	select {
+	case s.requests <- jobRequest:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting new requests. Buffered requests are still delivered, after that the source is exhausted.
+func (s *ChannelSource) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.requests)
+	}
+}
+
+// Next implements Source.
+func (s *ChannelSource) Next(ctx context.Context) (*puppetmaster.JobRequest, func(error)) {
+	if ctx.Err() != nil {
+		return nil, nil
+	}
+
+	s.retryMu.Lock()
+	if len(s.retry) > 0 {
+		jobRequest := s.retry[0]
+		s.retry = s.retry[1:]
+		s.retryMu.Unlock()
+
+		return jobRequest, s.ack(jobRequest)
+	}
+	s.retryMu.Unlock()
+
+	select {
+	case jobRequest, ok := <-s.requests:
+		if !ok {
+			return nil, nil
+		}
+
+		return jobRequest, s.ack(jobRequest)
+	case <-ctx.Done():
+		return nil, nil
+	}
+}
+
+func (s *ChannelSource) ack(jobRequest *puppetmaster.JobRequest) func(error) {
+	return func(err error) {
+		if err == nil {
+			return
+		}
+
+		s.retryMu.Lock()
+		s.retry = append(s.retry, jobRequest)
+		s.retryMu.Unlock()
+	}
+}
@@ -0,0 +1,214 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+// file extensions of spooled requests, claimed ones are renamed so no other worker picks them up
+const (
+	spoolExt      = ".json"
+	processingExt = ".processing"
+)
+
+// SpoolSource is a Source reading job requests from JSON files in a directory, one request per file, in the order
+// they were written. Delivered requests are claimed by renaming their file, which is removed when the request is
+// handled and moved to the end of the spool when it was not, so no request is lost when the process dies and a
+// failing request does not block the ones behind it. A spool directory must only be consumed by a single process, as
+// claimed requests are released when a SpoolSource is opened.
+type SpoolSource struct {
+	dir          string
+	pollInterval time.Duration
+	seq          uint64
+}
+
+var _ Source = (*SpoolSource)(nil)
+
+// NewSpoolSource opens the spool in dir, creating the directory if it does not exist.
+func NewSpoolSource(dir string) (*SpoolSource, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %v", err)
+	}
+
+	// release requests claimed by a previous process that died while executing them
+	claimed, err := filepath.Glob(filepath.Join(dir, "*"+processingExt))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range claimed {
+		if err := os.Rename(path, strings.TrimSuffix(path, processingExt)+spoolExt); err != nil {
+			return nil, fmt.Errorf("failed to release %v: %v", path, err)
+		}
+	}
+
+	return &SpoolSource{dir: dir, pollInterval: time.Second}, nil
+}
+
+// SetPollInterval sets how often an empty spool is checked for new files, every second by default.
+func (s *SpoolSource) SetPollInterval(interval time.Duration) {
+	s.pollInterval = interval
+}
+
+// spooledRequest is the format of spooled files: the JSON of the JobRequest, plus its idempotency key, which is not
+// part of the JSON of a JobRequest as it is sent as header.
+type spooledRequest struct {
+	*puppetmaster.JobRequest
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// Put writes a request to the spool. Files can be put into the directory by other processes as well, as long as
+// they are written atomically, e.g. by renaming them into place, and use the .json extension.
+func (s *SpoolSource) Put(jobRequest *puppetmaster.JobRequest) error {
+	return writeFileAtomic(s.nextPath(), &spooledRequest{JobRequest: jobRequest, IdempotencyKey: jobRequest.IdempotencyKey})
+}
+
+// nextPath returns the path of a new file at the end of the spool.
+func (s *SpoolSource) nextPath() string {
+	name := fmt.Sprintf("%020d-%06d", time.Now().UnixNano(), atomic.AddUint64(&s.seq, 1)%1000000)
+
+	return filepath.Join(s.dir, name+spoolExt)
+}
+
+// Next implements Source.
+func (s *SpoolSource) Next(ctx context.Context) (*puppetmaster.JobRequest, func(error)) {
+	for {
+		if jobRequest, ack := s.claim(); jobRequest != nil {
+			return jobRequest, ack
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+// claim returns the oldest spooled request that could be read, or nil if there is none.
+func (s *SpoolSource) claim() (*puppetmaster.JobRequest, func(error)) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*"+spoolExt))
+	if err != nil {
+		return nil, nil
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		claimed := strings.TrimSuffix(path, spoolExt) + processingExt
+
+		// renaming fails if another worker claimed the file first
+		if err := os.Rename(path, claimed); err != nil {
+			continue
+		}
+
+		jobRequest, err := readJobRequest(claimed)
+		if err != nil {
+			// keep unreadable files out of the way, so they do not block the spool
+			_ = os.Rename(claimed, claimed+".invalid")
+			continue
+		}
+
+		return jobRequest, func(err error) {
+			if err == nil {
+				_ = os.Remove(claimed)
+				return
+			}
+
+			_ = os.Rename(claimed, s.nextPath())
+		}
+	}
+
+	return nil, nil
+}
+
+// FileDeadLetter is a DeadLetter writing every failed job as a JSON file into a directory.
+type FileDeadLetter struct {
+	dir string
+	seq uint64
+}
+
+var _ DeadLetter = (*FileDeadLetter)(nil)
+
+// DeadLetterRecord is the content of the files written by FileDeadLetter.
+type DeadLetterRecord struct {
+	Request  *puppetmaster.JobRequest `json:"request"`
+	Job      *puppetmaster.Job        `json:"job,omitempty"`
+	Error    string                   `json:"error"`
+	FailedAt time.Time                `json:"failed_at"`
+	// IdempotencyKey is the key of Request, which is not part of its JSON.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// NewFileDeadLetter returns a FileDeadLetter writing into dir, creating the directory if it does not exist.
+func NewFileDeadLetter(dir string) (*FileDeadLetter, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create dead letter directory: %v", err)
+	}
+
+	return &FileDeadLetter{dir: dir}, nil
+}
+
+// DeadLetter implements DeadLetter.
+func (d *FileDeadLetter) DeadLetter(jobRequest *puppetmaster.JobRequest, job *puppetmaster.Job, err error) error {
+	record := &DeadLetterRecord{
+		Request:        jobRequest,
+		IdempotencyKey: jobRequest.IdempotencyKey,
+		Job:            job,
+		Error:          err.Error(),
+		FailedAt:       time.Now(),
+	}
+
+	name := fmt.Sprintf("%020d-%06d.json", record.FailedAt.UnixNano(), atomic.AddUint64(&d.seq, 1)%1000000)
+
+	return writeFileAtomic(filepath.Join(d.dir, name), record)
+}
+
+func readJobRequest(path string) (*puppetmaster.JobRequest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	spooled := &spooledRequest{JobRequest: &puppetmaster.JobRequest{}}
+	if err := json.Unmarshal(b, spooled); err != nil {
+		return nil, err
+	}
+	spooled.JobRequest.IdempotencyKey = spooled.IdempotencyKey
+
+	return spooled.JobRequest, nil
+}
+
+// writeFileAtomic writes v as JSON to a temporary file and renames it into place, so readers never see partial files.
+func writeFileAtomic(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
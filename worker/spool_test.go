@@ -0,0 +1,156 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+func countFiles(t *testing.T, dir, pattern string) int {
+	paths, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return len(paths)
+}
+
+func TestSpoolSource(t *testing.T) {
+	dir := t.TempDir()
+	source, err := NewSpoolSource(dir)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+
+	for _, code := range []string{"first", "second"} {
+		if err := source.Put(request(code, map[string]string{"page": code})); err != nil {
+			t.Fatalf("failed to put %v: %v", code, err)
+		}
+	}
+
+	first, ack := source.Next(context.Background())
+	if first == nil || first.Code != "first" || first.Vars["page"] != "first" {
+		t.Fatalf("Expected first request, got %+v", first)
+	}
+
+	if n := countFiles(t, dir, "*"+processingExt); n != 1 {
+		t.Errorf("Expected one claimed file, got %d", n)
+	}
+
+	// failed requests are put back at the end and delivered again
+	ack(errors.New("try again"))
+
+	second, ack := source.Next(context.Background())
+	if second == nil || second.Code != "second" {
+		t.Fatalf("Expected second request, got %+v", second)
+	}
+	ack(nil)
+
+	again, _ := source.Next(context.Background())
+	if again == nil || again.Code != "first" {
+		t.Fatalf("Expected first request again, got %+v", again)
+	}
+
+	// a new source releases the claim of the crashed one
+	if _, err := NewSpoolSource(dir); err != nil {
+		t.Fatalf("failed to reopen spool: %v", err)
+	}
+
+	if n := countFiles(t, dir, "*"+spoolExt); n != 1 {
+		t.Errorf("Expected the claimed request to be released, got %d files", n)
+	}
+
+	source.SetPollInterval(time.Millisecond)
+	if _, ack := source.Next(context.Background()); ack == nil {
+		t.Fatal("Expected released request to be delivered")
+	} else {
+		ack(nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if r, _ := source.Next(ctx); r != nil {
+		t.Errorf("Expected no request from empty spool, got %+v", r)
+	}
+}
+
+func TestSpoolSource_IdempotencyKey(t *testing.T) {
+	source, err := NewSpoolSource(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+
+	jobRequest := request("keyed", nil)
+	jobRequest.IdempotencyKey = "order-42"
+	if err := source.Put(jobRequest); err != nil {
+		t.Fatalf("failed to put request: %v", err)
+	}
+
+	r, _ := source.Next(context.Background())
+	if r == nil || r.Code != "keyed" || r.IdempotencyKey != "order-42" {
+		t.Errorf("Expected request with idempotency key, got %+v", r)
+	}
+}
+
+func TestSpoolSource_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "0-broken.json"), []byte("{nope"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := NewSpoolSource(dir)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+
+	if err := source.Put(request("valid", nil)); err != nil {
+		t.Fatalf("failed to put request: %v", err)
+	}
+
+	if r, _ := source.Next(context.Background()); r == nil || r.Code != "valid" {
+		t.Errorf("Expected invalid file to be skipped, got %+v", r)
+	}
+
+	if n := countFiles(t, dir, "*.invalid"); n != 1 {
+		t.Errorf("Expected invalid file to be moved aside, got %d", n)
+	}
+}
+
+func TestFileDeadLetter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "failed")
+	deadLetter, err := NewFileDeadLetter(dir)
+	if err != nil {
+		t.Fatalf("failed to create dead letter: %v", err)
+	}
+
+	job := &puppetmaster.Job{UUID: "abc", Error: "boom"}
+	if err := deadLetter.DeadLetter(request("broken", nil), job, errors.New("job abc failed: boom")); err != nil {
+		t.Fatalf("failed to dead letter: %v", err)
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(paths) != 1 {
+		t.Fatalf("Expected one dead letter file, got %v", paths)
+	}
+
+	b, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := &DeadLetterRecord{}
+	if err := json.Unmarshal(b, record); err != nil {
+		t.Fatalf("failed to decode dead letter: %v", err)
+	}
+
+	if record.Request.Code != "broken" || record.Job.UUID != "abc" || record.Error != "job abc failed: boom" {
+		t.Errorf("Unexpected dead letter record %+v", record)
+	}
+}
@@ -0,0 +1,376 @@
+// Package worker consumes job requests from a queue and executes them on the puppet master with a fixed number of
+// concurrent executors.
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+var (
+	// ErrAlreadyStarted is thrown when Start() is called on a running pool.
+	ErrAlreadyStarted = errors.New("pool was already started")
+
+	// ErrJobFailed is thrown when a job was executed, but failed with an error or was cancelled on the puppet master.
+	ErrJobFailed = errors.New("job failed")
+)
+
+// maxRetryBackoff caps the exponential backoff between two attempts of a request.
+const maxRetryBackoff = time.Minute
+
+// Source delivers the job requests to execute.
+type Source interface {
+	// Next blocks until a job request is available and returns it together with an ack function, which has to be
+	// called exactly once with the outcome of the job. A nil error means the request was handled and can be removed
+	// from the source, any other error means it was not and should be delivered again. Next returns a nil request
+	// when ctx is done or the source is exhausted.
+	Next(ctx context.Context) (*puppetmaster.JobRequest, func(error))
+}
+
+// DeadLetter stores jobs that failed, so they can be inspected or retried by hand.
+type DeadLetter interface {
+	DeadLetter(jobRequest *puppetmaster.JobRequest, job *puppetmaster.Job, err error) error
+}
+
+// DeadLetterFunc adapts an ordinary function to a DeadLetter.
+type DeadLetterFunc func(jobRequest *puppetmaster.JobRequest, job *puppetmaster.Job, err error) error
+
+// DeadLetter implements DeadLetter.
+func (f DeadLetterFunc) DeadLetter(jobRequest *puppetmaster.JobRequest, job *puppetmaster.Job, err error) error {
+	return f(jobRequest, job, err)
+}
+
+// Result is the outcome of a single job request.
+type Result struct {
+	Request  *puppetmaster.JobRequest
+	Job      *puppetmaster.Job
+	Err      error
+	Started  time.Time
+	Finished time.Time
+	// Attempts is the number of times the request was executed.
+	Attempts int
+	// DeadLettered is set when the failed job was handed to the DeadLetter.
+	DeadLettered bool
+}
+
+// Pool executes the requests of a Source with a fixed number of workers.
+type Pool struct {
+	executor    puppetmaster.Executor
+	source      Source
+	concurrency int
+	jobTimeout  time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	deadLetter  DeadLetter
+	handler     func(Result)
+
+	mu      sync.Mutex
+	started bool
+	workers sync.WaitGroup
+	done    chan struct{}
+
+	fetchCtx    context.Context
+	stopFetch   context.CancelFunc
+	runCtx      context.Context
+	cancelRun   context.CancelFunc
+	interrupted bool
+}
+
+// New returns a new Pool executing the requests of source through the given executor with a single worker.
+func New(executor puppetmaster.Executor, source Source) *Pool {
+	return &Pool{
+		executor:    executor,
+		source:      source,
+		concurrency: 1,
+		maxAttempts: 3,
+		backoff:     time.Second,
+	}
+}
+
+// SetConcurrency sets the number of jobs executed at the same time, 1 by default. It has to be called before Start().
+func (p *Pool) SetConcurrency(concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	p.concurrency = concurrency
+}
+
+// SetJobTimeout limits how long a single job may take, including waiting for it to finish on the puppet master. Jobs
+// exceeding it fail with context.DeadlineExceeded and are not executed again. 0, the default, disables the limit.
+func (p *Pool) SetJobTimeout(timeout time.Duration) {
+	p.jobTimeout = timeout
+}
+
+// SetMaxAttempts sets how often a request is executed when it fails with a transport error, e.g. because the puppet
+// master is unreachable, or a 5xx or 429 response, 3 by default. All other errors, like jobs that failed on the
+// puppet master or rejected requests, are final. Every request is given an idempotency key before the first attempt,
+// so a job that was created before the error is waited for instead of being created again.
+func (p *Pool) SetMaxAttempts(attempts int) {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	p.maxAttempts = attempts
+}
+
+// SetRetryBackoff sets how long to wait before the second attempt of a request, 1s by default. The wait doubles with
+// every further attempt, up to a minute.
+func (p *Pool) SetRetryBackoff(backoff time.Duration) {
+	if backoff < 0 {
+		backoff = 0
+	}
+
+	p.backoff = backoff
+}
+
+// SetDeadLetter sets where failed jobs are stored. Requests stored successfully are acked as handled, so the source
+// does not deliver them again. Without a dead letter, requests failing with a final error are acked as handled and
+// only reported to the handler; requests that still fail with a retryable error after all attempts, see
+// SetMaxAttempts(), are acked with their error, so the source delivers them again.
+func (p *Pool) SetDeadLetter(deadLetter DeadLetter) {
+	p.deadLetter = deadLetter
+}
+
+// SetHandler sets a function receiving the result of every job, e.g. for logging or metrics. It is called
+// concurrently by all workers.
+func (p *Pool) SetHandler(handler func(Result)) {
+	p.handler = handler
+}
+
+// Start starts the workers in the background. Jobs use a context derived from ctx, so cancelling it cancels all
+// running jobs; use Stop() for a graceful shutdown.
+func (p *Pool) Start(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started {
+		return ErrAlreadyStarted
+	}
+
+	p.started = true
+	p.interrupted = false
+	p.done = make(chan struct{})
+	p.fetchCtx, p.stopFetch = context.WithCancel(ctx)
+	p.runCtx, p.cancelRun = context.WithCancel(ctx)
+
+	for i := 0; i < p.concurrency; i++ {
+		p.workers.Add(1)
+		go p.work()
+	}
+
+	go func(done chan struct{}) {
+		p.workers.Wait()
+		close(done)
+	}(p.done)
+
+	return nil
+}
+
+// Done returns a channel that is closed once all workers exited, either because the source is exhausted or the pool
+// was stopped.
+func (p *Pool) Done() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.done
+}
+
+// Stop stops taking new requests from the source and waits for the running jobs to finish. When ctx is done before,
+// the running jobs are cancelled, their requests are acked with the context's error so the source delivers them
+// again, and Stop returns the context's error once all workers exited.
+func (p *Pool) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.started {
+		p.mu.Unlock()
+		return nil
+	}
+	p.started = false
+	done := p.done
+	p.mu.Unlock()
+
+	p.stopFetch()
+	defer p.cancelRun()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		p.interrupted = true
+		p.mu.Unlock()
+
+		p.cancelRun()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.workers.Done()
+
+	for {
+		jobRequest, ack := p.source.Next(p.fetchCtx)
+		if jobRequest == nil {
+			return
+		}
+
+		ack(p.execute(jobRequest))
+	}
+}
+
+// execute runs a single job, retrying retryable errors, and returns the error to ack its request with.
+func (p *Pool) execute(jobRequest *puppetmaster.JobRequest) error {
+	jobRequest, err := withIdempotencyKey(jobRequest)
+	if err != nil {
+		return err
+	}
+
+	result := Result{Request: jobRequest, Started: time.Now()}
+
+	for {
+		result.Attempts++
+		result.Job, result.Err = p.attempt(jobRequest)
+
+		if result.Err == nil || !retryable(result.Err) || result.Attempts >= p.maxAttempts || p.isInterrupted() {
+			break
+		}
+
+		if !p.wait(p.retryBackoff(result.Attempts)) {
+			break
+		}
+	}
+	result.Finished = time.Now()
+
+	ackErr := result.Err
+	switch {
+	case result.Err == nil || p.isInterrupted():
+	case p.deadLetter != nil:
+		if err := p.deadLetter.DeadLetter(jobRequest, result.Job, result.Err); err != nil {
+			ackErr = fmt.Errorf("failed to dead letter job: %v (job error: %v)", err, result.Err)
+		} else {
+			result.DeadLettered = true
+			ackErr = nil
+		}
+	case !retryable(result.Err):
+		// executing the request again would fail the same way
+		ackErr = nil
+	}
+
+	if p.handler != nil {
+		p.handler(result)
+	}
+
+	return ackErr
+}
+
+// attempt executes the request once.
+func (p *Pool) attempt(jobRequest *puppetmaster.JobRequest) (*puppetmaster.Job, error) {
+	ctx := p.runCtx
+	if p.jobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.jobTimeout)
+		defer cancel()
+	}
+
+	job, err := p.executor.ExecuteSyncWithOptions(ctx, jobRequest, nil)
+	if err != nil {
+		return job, err
+	}
+
+	return job, jobError(job)
+}
+
+// retryBackoff returns how long to wait after the given attempt.
+func (p *Pool) retryBackoff(attempt int) time.Duration {
+	backoff := p.backoff
+	for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+
+	return backoff
+}
+
+// wait sleeps for d and returns false if the running jobs were cancelled in the meantime.
+func (p *Pool) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-p.runCtx.Done():
+		return false
+	}
+}
+
+// isInterrupted returns true if running jobs were cancelled by Stop(). They did not fail on their own and are
+// delivered again instead of being dead lettered.
+func (p *Pool) isInterrupted() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.interrupted || p.runCtx.Err() != nil
+}
+
+func jobError(job *puppetmaster.Job) error {
+	switch {
+	case job == nil:
+		return errors.New("executor returned no job")
+	case job.Status == puppetmaster.StatusCancelled:
+		return fmt.Errorf("%w: job %v was cancelled", ErrJobFailed, job.UUID)
+	case job.Error != "":
+		return fmt.Errorf("%w: job %v: %v", ErrJobFailed, job.UUID, job.Error)
+	}
+
+	return nil
+}
+
+// retryable returns true for errors that may be gone when executing the request again: transport errors and
+// responses telling the puppet master is overloaded or failing. Timeouts of the job and interrupts are final.
+func retryable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var responseErr *puppetmaster.ResponseError
+	if errors.As(err, &responseErr) {
+		return responseErr.StatusCode >= 500 || responseErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	// *url.Error implements net.Error, a connection dropped while reading a body does not
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// withIdempotencyKey returns a copy of the request with a random idempotency key, unless it has one already. The
+// request itself is not modified, it belongs to the source.
+func withIdempotencyKey(jobRequest *puppetmaster.JobRequest) (*puppetmaster.JobRequest, error) {
+	if jobRequest.IdempotencyKey != "" {
+		return jobRequest, nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate idempotency key: %v", err)
+	}
+
+	keyed := *jobRequest
+	keyed.IdempotencyKey = hex.EncodeToString(b)
+
+	return &keyed, nil
+}
@@ -0,0 +1,370 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	puppetmaster "github.com/scalify/puppet-master-client-go"
+)
+
+// fakeExecutor returns a done job named after the request's code. Requests with the var "fail" fail on the puppet
+// master, requests with the var "transport" fail to reach it, requests with the var "status" get a response with
+// that status code, requests with the var "block" block until their context is done.
+type fakeExecutor struct {
+	mu       sync.Mutex
+	running  int
+	maxRun   int
+	executed []string
+}
+
+func (f *fakeExecutor) ExecuteSyncWithOptions(ctx context.Context, jobRequest *puppetmaster.JobRequest, opts *puppetmaster.SyncOptions) (*puppetmaster.Job, error) {
+	f.mu.Lock()
+	f.running++
+	if f.running > f.maxRun {
+		f.maxRun = f.running
+	}
+	f.executed = append(f.executed, jobRequest.Code)
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.running--
+		f.mu.Unlock()
+	}()
+
+	if d := jobRequest.Vars["sleep"]; d != "" {
+		wait, _ := time.ParseDuration(d)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if jobRequest.Vars["transport"] != "" {
+		return nil, &url.Error{Op: "Post", URL: "http://puppet-master/jobs", Err: errors.New("connection refused")}
+	}
+
+	if status, _ := strconv.Atoi(jobRequest.Vars["status"]); status != 0 {
+		return nil, &puppetmaster.ResponseError{StatusCode: status, Status: http.StatusText(status)}
+	}
+
+	if jobRequest.Vars["block"] != "" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	job := &puppetmaster.Job{UUID: jobRequest.Code, Code: jobRequest.Code, Status: puppetmaster.StatusDone}
+	if jobRequest.Vars["fail"] != "" {
+		job.Error = "failed on purpose"
+	}
+
+	return job, nil
+}
+
+// recordingDeadLetter collects all dead lettered requests.
+type recordingDeadLetter struct {
+	mu       sync.Mutex
+	requests []string
+	errs     []error
+}
+
+func (d *recordingDeadLetter) DeadLetter(jobRequest *puppetmaster.JobRequest, job *puppetmaster.Job, err error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.requests = append(d.requests, jobRequest.Code)
+	d.errs = append(d.errs, err)
+
+	return nil
+}
+
+func request(code string, vars map[string]string) *puppetmaster.JobRequest {
+	return &puppetmaster.JobRequest{Code: code, Vars: vars}
+}
+
+func pushAll(t *testing.T, source *ChannelSource, requests ...*puppetmaster.JobRequest) {
+	for _, r := range requests {
+		if err := source.Push(context.Background(), r); err != nil {
+			t.Fatalf("failed to push %v: %v", r.Code, err)
+		}
+	}
+}
+
+func waitDone(t *testing.T, pool *Pool) {
+	select {
+	case <-pool.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("pool did not finish in time")
+	}
+}
+
+func TestPool_Concurrency(t *testing.T) {
+	executor := &fakeExecutor{}
+	source := NewChannelSource(10)
+	pushAll(t, source,
+		request("a", map[string]string{"sleep": "20ms"}),
+		request("b", map[string]string{"sleep": "20ms"}),
+		request("c", map[string]string{"sleep": "20ms"}),
+		request("d", map[string]string{"sleep": "20ms"}),
+	)
+	source.Close()
+
+	var mu sync.Mutex
+	var results []Result
+
+	pool := New(executor, source)
+	pool.SetConcurrency(2)
+	pool.SetHandler(func(r Result) {
+		mu.Lock()
+		results = append(results, r)
+		mu.Unlock()
+	})
+
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start pool: %v", err)
+	}
+	waitDone(t, pool)
+
+	if len(results) != 4 {
+		t.Errorf("Expected 4 results, got %d", len(results))
+	}
+
+	if executor.maxRun != 2 {
+		t.Errorf("Expected 2 jobs to run at once, got %d", executor.maxRun)
+	}
+
+	if err := pool.Start(context.Background()); err != ErrAlreadyStarted {
+		t.Errorf("Expected ErrAlreadyStarted, got %v", err)
+	}
+}
+
+func TestPool_DeadLetter(t *testing.T) {
+	cases := []struct {
+		deadLetter  bool
+		timeout     time.Duration
+		request     *puppetmaster.JobRequest
+		expAttempts int
+		expDeadLtr  bool
+		expRequeued bool
+	}{
+		{deadLetter: true, request: request("ok", nil), expAttempts: 1},
+		{deadLetter: true, request: request("broken", map[string]string{"fail": "1"}), expAttempts: 1, expDeadLtr: true},
+		{deadLetter: true, timeout: 10 * time.Millisecond, request: request("slow", map[string]string{"block": "1"}), expAttempts: 1, expDeadLtr: true},
+		{deadLetter: true, request: request("offline", map[string]string{"transport": "1"}), expAttempts: 3, expDeadLtr: true},
+		{deadLetter: false, request: request("broken", map[string]string{"fail": "1"}), expAttempts: 1},
+		{deadLetter: false, timeout: 10 * time.Millisecond, request: request("slow", map[string]string{"block": "1"}), expAttempts: 1},
+		{deadLetter: false, request: request("offline", map[string]string{"transport": "1"}), expAttempts: 3, expRequeued: true},
+		{deadLetter: false, request: request("overloaded", map[string]string{"status": "503"}), expAttempts: 3, expRequeued: true},
+		{deadLetter: false, request: request("throttled", map[string]string{"status": "429"}), expAttempts: 3, expRequeued: true},
+		{deadLetter: false, request: request("forbidden", map[string]string{"status": "403"}), expAttempts: 1},
+		{deadLetter: false, request: request("missing", map[string]string{"status": "404"}), expAttempts: 1},
+	}
+
+	for i, c := range cases {
+		source := NewChannelSource(1)
+		pushAll(t, source, c.request)
+		source.Close()
+
+		deadLetter := &recordingDeadLetter{}
+		pool := New(&fakeExecutor{}, source)
+		pool.SetJobTimeout(c.timeout)
+		pool.SetRetryBackoff(time.Millisecond)
+		if c.deadLetter {
+			pool.SetDeadLetter(deadLetter)
+		}
+
+		results := make(chan Result, 1)
+		pool.SetHandler(func(r Result) {
+			// stop after the first result, failed requests would be delivered again
+			select {
+			case results <- r:
+			default:
+			}
+		})
+
+		if err := pool.Start(context.Background()); err != nil {
+			t.Fatalf("case %d: failed to start pool: %v", i, err)
+		}
+
+		result := <-results
+		if err := pool.Stop(context.Background()); err != nil {
+			t.Fatalf("case %d: failed to stop pool: %v", i, err)
+		}
+
+		if result.DeadLettered != c.expDeadLtr || (len(deadLetter.requests) == 1) != c.expDeadLtr {
+			t.Errorf("case %d: Expected dead lettered %v, got %v (%v)", i, c.expDeadLtr, result.DeadLettered, deadLetter.requests)
+		}
+
+		if result.Attempts != c.expAttempts {
+			t.Errorf("case %d: Expected %d attempts, got %d", i, c.expAttempts, result.Attempts)
+		}
+
+		if c.timeout > 0 && !errors.Is(result.Err, context.DeadlineExceeded) {
+			t.Errorf("case %d: Expected deadline exceeded, got %v", i, result.Err)
+		}
+
+		source.retryMu.Lock()
+		requeued := len(source.retry) == 1
+		source.retryMu.Unlock()
+
+		if requeued != c.expRequeued {
+			t.Errorf("case %d: Expected requeued %v, got %v", i, c.expRequeued, requeued)
+		}
+	}
+}
+
+func TestPool_StopDrains(t *testing.T) {
+	executor := &fakeExecutor{}
+	source := NewChannelSource(10)
+	pushAll(t, source, request("running", map[string]string{"sleep": "50ms"}))
+
+	pool := New(executor, source)
+	finished := make(chan Result, 1)
+	pool.SetHandler(func(r Result) { finished <- r })
+
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start pool: %v", err)
+	}
+
+	// give the worker time to pick up the request
+	time.Sleep(10 * time.Millisecond)
+
+	if err := pool.Stop(context.Background()); err != nil {
+		t.Fatalf("failed to stop pool: %v", err)
+	}
+
+	if r := <-finished; r.Err != nil {
+		t.Errorf("Expected running job to finish, got %v", r.Err)
+	}
+
+	// requests pushed after stopping are not taken anymore
+	pushAll(t, source, request("late", nil))
+	if len(executor.executed) != 1 {
+		t.Errorf("Expected one executed job, got %v", executor.executed)
+	}
+}
+
+func TestPool_StopTimeout(t *testing.T) {
+	source := NewChannelSource(10)
+	pushAll(t, source, request("stuck", map[string]string{"block": "1"}))
+
+	deadLetter := &recordingDeadLetter{}
+	pool := New(&fakeExecutor{}, source)
+	pool.SetDeadLetter(deadLetter)
+
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start pool: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := pool.Stop(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if len(deadLetter.requests) != 0 {
+		t.Errorf("Expected interrupted job not to be dead lettered, got %v", deadLetter.requests)
+	}
+
+	if len(source.retry) != 1 {
+		t.Errorf("Expected interrupted job to be delivered again, got %d retries", len(source.retry))
+	}
+}
+
+func TestPool_RetryBackoff(t *testing.T) {
+	pool := New(&fakeExecutor{}, NewChannelSource(1))
+	pool.SetRetryBackoff(10 * time.Second)
+
+	cases := []struct {
+		attempt int
+		exp     time.Duration
+	}{
+		{attempt: 1, exp: 10 * time.Second},
+		{attempt: 2, exp: 20 * time.Second},
+		{attempt: 3, exp: 40 * time.Second},
+		{attempt: 4, exp: maxRetryBackoff},
+		{attempt: 100, exp: maxRetryBackoff},
+	}
+
+	for _, c := range cases {
+		if backoff := pool.retryBackoff(c.attempt); backoff != c.exp {
+			t.Errorf("Expected backoff %v after attempt %d, got %v", c.exp, c.attempt, backoff)
+		}
+	}
+}
+
+// pollFailingServer creates jobs and fails the first poll of every job with 502, counting the created jobs.
+type pollFailingServer struct {
+	mu     sync.Mutex
+	posts  int
+	polled map[string]bool
+}
+
+func (s *pollFailingServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if req.Method == http.MethodPost {
+		s.posts++
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(rw, `{"data": {"uuid": "job-%d", "code": "test", "status": "queued"}}`, s.posts)
+		return
+	}
+
+	uuid := path.Base(req.URL.Path)
+	if !s.polled[uuid] {
+		s.polled[uuid] = true
+		rw.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	_, _ = fmt.Fprintf(rw, `{"data": {"uuid": %q, "code": "test", "status": "done"}}`, uuid)
+}
+
+func TestPool_RetryWaitsForCreatedJob(t *testing.T) {
+	server := &pollFailingServer{polled: map[string]bool{}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	client, err := puppetmaster.NewClient(httpServer.URL, "token")
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	client.SetSyncSleepMs(1)
+
+	source := NewChannelSource(1)
+	pushAll(t, source, request("test", nil))
+	source.Close()
+
+	var result Result
+	pool := New(client, source)
+	pool.SetRetryBackoff(time.Millisecond)
+	pool.SetHandler(func(r Result) { result = r })
+
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start pool: %v", err)
+	}
+	waitDone(t, pool)
+
+	if result.Err != nil || result.Attempts != 2 || result.Job == nil || result.Job.UUID != "job-1" {
+		t.Errorf("Expected the created job to be waited for on the second attempt, got %+v", result)
+	}
+
+	if server.posts != 1 {
+		t.Errorf("Expected exactly one job to be created, got %d", server.posts)
+	}
+}